@@ -2,17 +2,24 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/zigamedved/cronsentry/internal/api"
 	"github.com/zigamedved/cronsentry/internal/db"
+	"github.com/zigamedved/cronsentry/internal/models"
 	"github.com/zigamedved/cronsentry/internal/notifications"
 	"github.com/zigamedved/cronsentry/internal/notifications/integrations"
+	"github.com/zigamedved/cronsentry/internal/sso"
+	"github.com/zigamedved/cronsentry/internal/templates"
 )
 
 func main() {
@@ -29,16 +36,78 @@ func main() {
 	}
 	logger.Println("Database initialized successfully")
 
-	sendgridClient := integrations.NewSendgridSendClient("API_KEY", logger, false)
+	if owner := os.Getenv("LEGACY_DATA_OWNER"); owner != "" {
+		claimed, err := database.ClaimLegacyData(owner)
+		if err != nil {
+			logger.Fatalf("Failed to claim legacy data: %v", err)
+		}
+		if claimed {
+			logger.Printf("Moved the data of the development user to %s", owner)
+		}
+	}
+
+	dashboardURL := getEnv("DASHBOARD_URL", "http://localhost:3000")
+	allowPrivateWebhooks, err := strconv.ParseBool(getEnv("ALLOW_PRIVATE_WEBHOOKS", "false"))
+	if err != nil {
+		logger.Fatalf("Invalid ALLOW_PRIVATE_WEBHOOKS: %v", err)
+	}
+
+	// the endpoints of httpClient are configured here, webhookClient connects
+	// to whatever users enter
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	webhookClient := integrations.NewWebhookClient(10*time.Second, allowPrivateWebhooks)
+
+	emailSender, err := newEmailSender(logger)
+	if err != nil {
+		logger.Fatalf("Failed to configure email: %v", err)
+	}
+
+	renderer, err := templates.NewRenderer(dashboardURL)
+	if err != nil {
+		logger.Fatalf("Failed to load templates: %v", err)
+	}
+
+	registry := notifications.NewRegistry()
+	registry.Register(models.ChannelEmail, notifications.NewEmailNotifier(emailSender))
+	registry.Register(models.ChannelSlack, integrations.NewSlackNotifier(webhookClient, dashboardURL))
+	registry.Register(models.ChannelDiscord, integrations.NewDiscordNotifier(webhookClient, dashboardURL))
+	registry.Register(models.ChannelTeams, integrations.NewTeamsNotifier(webhookClient, dashboardURL))
+	registry.Register(models.ChannelWebhook, integrations.NewWebhookNotifier(webhookClient))
+	registry.Register(models.ChannelPagerDuty, integrations.NewPagerDutyNotifier(
+		httpClient, getEnv("PAGERDUTY_URL", integrations.DefaultPagerDutyURL), dashboardURL,
+	))
+	registry.Register(models.ChannelOpsgenie, integrations.NewOpsgenieNotifier(
+		httpClient,
+		getEnv("OPSGENIE_URL", integrations.DefaultOpsgenieURL),
+		getEnv("OPSGENIE_EU_URL", integrations.DefaultOpsgenieEUURL),
+		dashboardURL,
+	))
+
 	notificationProcessor := notifications.NewNotificationProcessor(
 		database.GetDB(),
-		sendgridClient,
+		database.Listen(db.NotificationQueuedChannel, logger),
+		registry,
+		renderer,
 		logger,
 	)
 	notificationProcessor.Start()
 	logger.Println("Notification processor started")
 
-	server := api.NewServer(database, logger)
+	jobChecker := db.NewJobChecker(database, logger)
+	jobChecker.Start()
+	logger.Println("Job checker started")
+
+	provider, err := newSSOProvider(logger)
+	if err != nil {
+		logger.Fatalf("Failed to configure SSO: %v", err)
+	}
+
+	allowRegistration, err := strconv.ParseBool(getEnv("ALLOW_REGISTRATION", "false"))
+	if err != nil {
+		logger.Fatalf("Invalid ALLOW_REGISTRATION: %v", err)
+	}
+
+	server := api.NewServer(database, registry, renderer, provider, allowRegistration, dashboardURL, logger)
 	addr := ":8080"
 	if port := os.Getenv("PORT"); port != "" {
 		addr = ":" + port
@@ -58,10 +127,6 @@ func main() {
 		}
 	}()
 
-	jobChecker := db.NewJobChecker(database, logger)
-	jobChecker.Start()
-	logger.Println("Job checker started")
-
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -83,3 +148,66 @@ func main() {
 
 	logger.Println("Server exited properly")
 }
+
+// newEmailSender sends through SMTP when SMTP_HOST is set, otherwise through
+// SendGrid. Without SENDGRID_API_KEY emails are only logged.
+func newEmailSender(logger *log.Logger) (notifications.EmailSender, error) {
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port, err := strconv.Atoi(getEnv("SMTP_PORT", "587"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SMTP_PORT: %w", err)
+		}
+		logger.Printf("Sending email through SMTP server %s:%d", host, port)
+		return integrations.NewSMTPClient(integrations.SMTPConfig{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     getEnv("SMTP_FROM", "CronSentry <cronsentry@localhost>"),
+			Security: os.Getenv("SMTP_SECURITY"),
+		})
+	}
+
+	apiKey := os.Getenv("SENDGRID_API_KEY")
+	if apiKey == "" {
+		logger.Println("No email provider configured, emails are only logged")
+	}
+	return integrations.NewSendgridSendClient(apiKey, logger, apiKey != ""), nil
+}
+
+// newSSOProvider enables signing in with an OpenID Connect provider when
+// OIDC_ISSUER is set.
+func newSSOProvider(logger *log.Logger) (*sso.Provider, error) {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+
+	clientID := os.Getenv("OIDC_CLIENT_ID")
+	if clientID == "" {
+		return nil, fmt.Errorf("OIDC_CLIENT_ID is required with OIDC_ISSUER")
+	}
+
+	mapping, err := sso.ParseGroupMapping(os.Getenv("OIDC_GROUP_MAPPING"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OIDC_GROUP_MAPPING: %w", err)
+	}
+
+	logger.Printf("Signing in with SSO through %s", issuer)
+	return sso.NewProvider(sso.Config{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  getEnv("OIDC_REDIRECT_URL", "http://localhost:8080/api/auth/oidc/callback"),
+		Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+		GroupsClaim:  os.Getenv("OIDC_GROUPS_CLAIM"),
+		GroupMapping: mapping,
+	}), nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
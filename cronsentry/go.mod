@@ -3,9 +3,15 @@ module github.com/zigamedved/cronsentry
 go 1.22.4
 
 require (
-	github.com/adhocore/gronx v1.19.5 // indirect
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/lib/pq v1.10.9 // indirect
-	github.com/sendgrid/rest v2.6.9+incompatible // indirect
-	github.com/sendgrid/sendgrid-go v3.16.0+incompatible // indirect
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/adhocore/gronx v1.19.5
+	github.com/coreos/go-oidc/v3 v3.11.0
+	github.com/go-jose/go-jose/v4 v4.0.2
+	github.com/google/uuid v1.6.0
+	github.com/lib/pq v1.10.9
+	github.com/sendgrid/sendgrid-go v3.16.0+incompatible
+	golang.org/x/crypto v0.31.0
+	golang.org/x/oauth2 v0.21.0
 )
+
+require github.com/sendgrid/rest v2.6.9+incompatible // indirect
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+// requireSession rejects requests made with an API key. Credentials are
+// managed from a session only, so a leaked key cannot mint credentials that
+// outlive it.
+func (s *Server) requireSession(w http.ResponseWriter, r *http.Request) bool {
+	if apiKeyIDFrom(r) != "" {
+		http.Error(w, "API keys cannot manage credentials", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSession(w, r) {
+		return
+	}
+
+	var request struct {
+		Name      string     `json:"name"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if request.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	key := &models.APIKey{
+		UserID:    userIDFrom(r),
+		Name:      request.Name,
+		ExpiresAt: request.ExpiresAt,
+	}
+
+	if err := s.db.CreateAPIKey(key); err != nil {
+		s.logger.Printf("Error creating API key: %v", err)
+		http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+func (s *Server) handleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSession(w, r) {
+		return
+	}
+
+	keys, err := s.db.ListAPIKeysByUser(userIDFrom(r))
+	if err != nil {
+		s.logger.Printf("Error listing API keys: %v", err)
+		http.Error(w, "Failed to list API keys", http.StatusInternalServerError)
+		return
+	}
+
+	if keys == nil {
+		keys = make([]*models.APIKey, 0)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+func (s *Server) handleDeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSession(w, r) {
+		return
+	}
+
+	key, err := s.db.GetAPIKey(r.PathValue("id"))
+	if err != nil {
+		s.logger.Printf("Error getting API key: %v", err)
+		http.Error(w, "Failed to get API key", http.StatusInternalServerError)
+		return
+	}
+
+	if key == nil {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+
+	if key.UserID != userIDFrom(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if err := s.db.DeleteAPIKey(key.ID); err != nil {
+		s.logger.Printf("Error deleting API key: %v", err)
+		http.Error(w, "Failed to delete API key", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
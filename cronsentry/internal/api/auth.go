@@ -0,0 +1,303 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/zigamedved/cronsentry/internal/db"
+	"github.com/zigamedved/cronsentry/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	sessionCookie = "cronsentry_session"
+	sessionTTL    = 30 * 24 * time.Hour
+
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt ignores anything longer
+)
+
+// dummyHash is compared against when the email is unknown, so a login takes
+// as long whether or not the user exists.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("cronsentry"), bcrypt.DefaultCost)
+
+type contextKey int
+
+const (
+	userIDKey contextKey = iota
+	apiKeyIDKey
+)
+
+// userIDFrom returns the authenticated user of a request that went through
+// requireAuth.
+func userIDFrom(r *http.Request) string {
+	id, _ := r.Context().Value(userIDKey).(string)
+	return id
+}
+
+// apiKeyIDFrom returns the API key a request was authenticated with, or an
+// empty string for requests with a session.
+func apiKeyIDFrom(r *http.Request) string {
+	id, _ := r.Context().Value(apiKeyIDKey).(string)
+	return id
+}
+
+// requireAuth only lets requests with a valid session or API key through, and
+// puts the ID of their user in the request context. API keys are sent as a
+// bearer token, sessions as a cookie.
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if header := r.Header.Get("Authorization"); header != "" {
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok || token == "" {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			keyID, id, err := s.db.UseAPIKey(token)
+			if err != nil {
+				s.logger.Printf("Error getting API key: %v", err)
+				http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+				return
+			}
+
+			if id == "" {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			ctx = context.WithValue(ctx, apiKeyIDKey, keyID)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, userIDKey, id)))
+			return
+		}
+
+		cookie, err := r.Cookie(sessionCookie)
+		if err != nil || cookie.Value == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := s.db.GetSessionUserID(cookie.Value)
+		if err != nil {
+			s.logger.Printf("Error getting session: %v", err)
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		if id == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, userIDKey, id)))
+	})
+}
+
+type credentials struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// registrationOpen reports whether anyone may sign up. Unless registration
+// is allowed, only the first account can be registered, further users are
+// added through SSO.
+func (s *Server) registrationOpen() (bool, error) {
+	if s.allowRegistration {
+		return true, nil
+	}
+
+	exists, err := s.db.HasAccounts()
+	if err != nil {
+		return false, err
+	}
+	return !exists, nil
+}
+
+// handleRegistrationConfig tells the dashboard whether to offer signing up.
+func (s *Server) handleRegistrationConfig(w http.ResponseWriter, r *http.Request) {
+	open, err := s.registrationOpen()
+	if err != nil {
+		s.logger.Printf("Error checking registration: %v", err)
+		http.Error(w, "Failed to check registration", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"enabled": open})
+}
+
+func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
+	open, err := s.registrationOpen()
+	if err != nil {
+		s.logger.Printf("Error checking registration: %v", err)
+		http.Error(w, "Failed to register", http.StatusInternalServerError)
+		return
+	}
+
+	if !open {
+		http.Error(w, "Registration is disabled", http.StatusForbidden)
+		return
+	}
+
+	var request credentials
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	address, err := mail.ParseAddress(strings.TrimSpace(request.Email))
+	if err != nil || address.Name != "" {
+		http.Error(w, "Valid email is required", http.StatusBadRequest)
+		return
+	}
+
+	if len(request.Password) < minPasswordLength || len(request.Password) > maxPasswordLength {
+		http.Error(w, "Password must be between 8 and 72 characters", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		name = address.Address[:strings.Index(address.Address, "@")]
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		s.logger.Printf("Error hashing password: %v", err)
+		http.Error(w, "Failed to register", http.StatusInternalServerError)
+		return
+	}
+
+	user := &models.User{
+		Email:    address.Address,
+		Name:     name,
+		Password: string(hash),
+	}
+
+	if err := s.db.CreateUser(user); err != nil {
+		if errors.Is(err, db.ErrEmailTaken) {
+			http.Error(w, "Email is already registered", http.StatusConflict)
+			return
+		}
+		s.logger.Printf("Error creating user: %v", err)
+		http.Error(w, "Failed to register", http.StatusInternalServerError)
+		return
+	}
+
+	if !s.startSession(w, r, user.ID) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(user)
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var request credentials
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.db.GetUserByEmail(strings.TrimSpace(request.Email))
+	if err != nil {
+		s.logger.Printf("Error getting user: %v", err)
+		http.Error(w, "Failed to log in", http.StatusInternalServerError)
+		return
+	}
+
+	hash := dummyHash
+	if user != nil {
+		hash = []byte(user.Password)
+	}
+
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(request.Password)); err != nil || user == nil {
+		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		return
+	}
+
+	if !s.startSession(w, r, user.ID) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil && cookie.Value != "" {
+		if err := s.db.DeleteSession(cookie.Value); err != nil {
+			s.logger.Printf("Error deleting session: %v", err)
+			http.Error(w, "Failed to log out", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleMe returns the authenticated user, so clients can tell whether their
+// session is still valid.
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	user, err := s.db.GetUser(userIDFrom(r))
+	if err != nil {
+		s.logger.Printf("Error getting user: %v", err)
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		return
+	}
+
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// startSession creates a session for the user and sets its cookie. It writes
+// an error response and returns false when that fails.
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, userID string) bool {
+	token, expiresAt, err := s.db.CreateSession(userID, sessionTTL)
+	if err != nil {
+		s.logger.Printf("Error creating session: %v", err)
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return false
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return true
+}
+
+// secureRequest reports whether the client connected over HTTPS, directly or
+// through a proxy.
+func secureRequest(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
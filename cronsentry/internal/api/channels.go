@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+func (s *Server) handleCreateChannel(w http.ResponseWriter, r *http.Request) {
+	var channelRequest struct {
+		Type   string          `json:"type"`
+		Name   string          `json:"name"`
+		Config json.RawMessage `json:"config"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&channelRequest); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if channelRequest.Type == "" || channelRequest.Name == "" {
+		http.Error(w, "Type and name are required", http.StatusBadRequest)
+		return
+	}
+
+	if len(channelRequest.Config) == 0 {
+		channelRequest.Config = json.RawMessage("{}")
+	}
+
+	if err := s.notifiers.Validate(channelRequest.Type, channelRequest.Config); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	channel := &models.Channel{
+		UserID: userIDFrom(r),
+		Type:   channelRequest.Type,
+		Name:   channelRequest.Name,
+		Config: channelRequest.Config,
+	}
+
+	if err := s.db.CreateChannel(channel); err != nil {
+		s.logger.Printf("Error creating channel: %v", err)
+		http.Error(w, "Failed to create channel", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(channel)
+}
+
+func (s *Server) handleListChannels(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFrom(r)
+
+	channels, err := s.db.ListChannelsByUser(userID)
+	if err != nil {
+		s.logger.Printf("Error listing channels: %v", err)
+		http.Error(w, "Failed to list channels", http.StatusInternalServerError)
+		return
+	}
+
+	if channels == nil {
+		channels = make([]*models.Channel, 0)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(channels)
+}
+
+func (s *Server) handleUpdateChannel(w http.ResponseWriter, r *http.Request) {
+	channel, ok := s.getOwnedChannel(w, r)
+	if !ok {
+		return
+	}
+
+	var channelRequest struct {
+		Name   string          `json:"name"`
+		Config json.RawMessage `json:"config"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&channelRequest); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if channelRequest.Name != "" {
+		channel.Name = channelRequest.Name
+	}
+	if len(channelRequest.Config) > 0 {
+		if err := s.notifiers.Validate(channel.Type, channelRequest.Config); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		channel.Config = channelRequest.Config
+	}
+
+	if err := s.db.UpdateChannel(channel); err != nil {
+		s.logger.Printf("Error updating channel: %v", err)
+		http.Error(w, "Failed to update channel", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(channel)
+}
+
+func (s *Server) handleDeleteChannel(w http.ResponseWriter, r *http.Request) {
+	channel, ok := s.getOwnedChannel(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.db.DeleteChannel(channel.ID); err != nil {
+		s.logger.Printf("Error deleting channel: %v", err)
+		http.Error(w, "Failed to delete channel", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// getOwnedChannel loads the channel named by the id path value and writes an
+// error response when it does not exist or belongs to another user.
+func (s *Server) getOwnedChannel(w http.ResponseWriter, r *http.Request) (*models.Channel, bool) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "Channel ID is required", http.StatusBadRequest)
+		return nil, false
+	}
+
+	channel, err := s.db.GetChannel(id)
+	if err != nil {
+		s.logger.Printf("Error getting channel: %v", err)
+		http.Error(w, "Failed to get channel", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if channel == nil {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	if channel.UserID != userIDFrom(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+
+	return channel, true
+}
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+type policyRequest struct {
+	Name  string                  `json:"name"`
+	Steps []models.EscalationStep `json:"steps"`
+}
+
+func (s *Server) handleCreatePolicy(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFrom(r)
+
+	var request policyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if request.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.validateSteps(request.Steps, userID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	policy := &models.EscalationPolicy{
+		UserID: userID,
+		Name:   request.Name,
+		Steps:  request.Steps,
+	}
+
+	if err := s.db.CreateEscalationPolicy(policy); err != nil {
+		s.logger.Printf("Error creating escalation policy: %v", err)
+		http.Error(w, "Failed to create escalation policy", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(policy)
+}
+
+func (s *Server) handleListPolicies(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFrom(r)
+
+	policies, err := s.db.ListEscalationPoliciesByUser(userID)
+	if err != nil {
+		s.logger.Printf("Error listing escalation policies: %v", err)
+		http.Error(w, "Failed to list escalation policies", http.StatusInternalServerError)
+		return
+	}
+
+	if policies == nil {
+		policies = make([]*models.EscalationPolicy, 0)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policies)
+}
+
+func (s *Server) handleUpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	policy, ok := s.getOwnedPolicy(w, r)
+	if !ok {
+		return
+	}
+
+	var request policyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if request.Name != "" {
+		policy.Name = request.Name
+	}
+	if request.Steps != nil {
+		if err := s.validateSteps(request.Steps, policy.UserID); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		policy.Steps = request.Steps
+	}
+
+	if err := s.db.UpdateEscalationPolicy(policy); err != nil {
+		s.logger.Printf("Error updating escalation policy: %v", err)
+		http.Error(w, "Failed to update escalation policy", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+func (s *Server) handleDeletePolicy(w http.ResponseWriter, r *http.Request) {
+	policy, ok := s.getOwnedPolicy(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.db.DeleteEscalationPolicy(policy.ID); err != nil {
+		s.logger.Printf("Error deleting escalation policy: %v", err)
+		http.Error(w, "Failed to delete escalation policy", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleAckJob acknowledges the current alert of a job, so the remaining
+// steps of its escalation policy are not notified.
+func (s *Server) handleAckJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	job, err := s.db.GetJob(id)
+	if err != nil {
+		s.logger.Printf("Error getting job: %v", err)
+		http.Error(w, "Failed to get job", http.StatusInternalServerError)
+		return
+	}
+
+	if job == nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	if job.UserID != userIDFrom(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	escalation, err := s.db.AckJob(job.ID, userIDFrom(r))
+	if err != nil {
+		s.logger.Printf("Error acknowledging job: %v", err)
+		http.Error(w, "Failed to acknowledge alert", http.StatusInternalServerError)
+		return
+	}
+
+	if escalation == nil {
+		http.Error(w, "Job has no unacknowledged alert", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(escalation)
+}
+
+// getOwnedPolicy loads the escalation policy named by the id path value and
+// writes an error response when it does not exist or belongs to another user.
+func (s *Server) getOwnedPolicy(w http.ResponseWriter, r *http.Request) (*models.EscalationPolicy, bool) {
+	policy, err := s.db.GetEscalationPolicy(r.PathValue("id"))
+	if err != nil {
+		s.logger.Printf("Error getting escalation policy: %v", err)
+		http.Error(w, "Failed to get escalation policy", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if policy == nil {
+		http.Error(w, "Escalation policy not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	if policy.UserID != userIDFrom(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+
+	return policy, true
+}
+
+// checkPolicy verifies that a job may use the escalation policy, and writes
+// an error response when it may not. A nil policy ID is always fine.
+func (s *Server) checkPolicy(w http.ResponseWriter, policyID *string, userID string) bool {
+	if policyID == nil {
+		return true
+	}
+
+	policy, err := s.db.GetEscalationPolicy(*policyID)
+	if err != nil {
+		s.logger.Printf("Error getting escalation policy: %v", err)
+		http.Error(w, "Failed to get escalation policy", http.StatusInternalServerError)
+		return false
+	}
+
+	if policy == nil || policy.UserID != userID {
+		http.Error(w, "Escalation policy not found", http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+// validateSteps requires at least one step, delays in ascending order, and
+// channels of the user in every step.
+func (s *Server) validateSteps(steps []models.EscalationStep, userID string) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("at least one step is required")
+	}
+
+	channels, err := s.db.ListChannelsByUser(userID)
+	if err != nil {
+		s.logger.Printf("Error listing channels: %v", err)
+		return fmt.Errorf("failed to list channels")
+	}
+
+	owned := make(map[string]bool, len(channels))
+	for _, channel := range channels {
+		owned[channel.ID] = true
+	}
+
+	for i, step := range steps {
+		if step.Delay < 0 {
+			return fmt.Errorf("step %d: delay must not be negative", i+1)
+		}
+		if i > 0 && step.Delay < steps[i-1].Delay {
+			return fmt.Errorf("step %d: delay must not be shorter than the delay of the previous step", i+1)
+		}
+		if len(step.ChannelIDs) == 0 {
+			return fmt.Errorf("step %d: at least one channel is required", i+1)
+		}
+		for _, channelID := range step.ChannelIDs {
+			if !owned[channelID] {
+				return fmt.Errorf("step %d: channel %s not found", i+1, channelID)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+const defaultDeadLetterLimit = 50
+
+func (s *Server) handleListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFrom(r)
+
+	limit := defaultDeadLetterLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 200 {
+			http.Error(w, "limit must be between 1 and 200", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	notifications, err := s.db.ListDeadNotifications(userID, limit)
+	if err != nil {
+		s.logger.Printf("Error listing dead notifications: %v", err)
+		http.Error(w, "Failed to list notifications", http.StatusInternalServerError)
+		return
+	}
+
+	if notifications == nil {
+		notifications = make([]*models.Notification, 0)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notifications)
+}
+
+func (s *Server) handleRetryNotification(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	notification, err := s.db.GetNotification(id)
+	if err != nil {
+		s.logger.Printf("Error getting notification: %v", err)
+		http.Error(w, "Failed to get notification", http.StatusInternalServerError)
+		return
+	}
+
+	if notification == nil {
+		http.Error(w, "Notification not found", http.StatusNotFound)
+		return
+	}
+
+	if notification.UserID != userIDFrom(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	retried, err := s.db.RetryNotification(id)
+	if err != nil {
+		s.logger.Printf("Error retrying notification: %v", err)
+		http.Error(w, "Failed to retry notification", http.StatusInternalServerError)
+		return
+	}
+
+	if !retried {
+		http.Error(w, "Only dead-lettered notifications can be retried", http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
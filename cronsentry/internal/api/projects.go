@@ -0,0 +1,295 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zigamedved/cronsentry/internal/db"
+	"github.com/zigamedved/cronsentry/internal/models"
+	"github.com/zigamedved/cronsentry/internal/schedule"
+)
+
+type projectRequest struct {
+	Name string `json:"name"`
+}
+
+func (s *Server) handleCreateProject(w http.ResponseWriter, r *http.Request) {
+	var request projectRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if request.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	project := &models.Project{
+		UserID: userIDFrom(r),
+		Name:   request.Name,
+	}
+
+	if err := s.db.CreateProject(project); err != nil {
+		s.logger.Printf("Error creating project: %v", err)
+		http.Error(w, "Failed to create project", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(project)
+}
+
+func (s *Server) handleListProjects(w http.ResponseWriter, r *http.Request) {
+	projects, err := s.db.ListProjectsByUser(userIDFrom(r))
+	if err != nil {
+		s.logger.Printf("Error listing projects: %v", err)
+		http.Error(w, "Failed to list projects", http.StatusInternalServerError)
+		return
+	}
+
+	if projects == nil {
+		projects = make([]*models.Project, 0)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(projects)
+}
+
+func (s *Server) handleUpdateProject(w http.ResponseWriter, r *http.Request) {
+	project, ok := s.getOwnedProject(w, r)
+	if !ok {
+		return
+	}
+
+	var request projectRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if request.Name != "" {
+		project.Name = request.Name
+	}
+
+	if err := s.db.UpdateProject(project); err != nil {
+		s.logger.Printf("Error updating project: %v", err)
+		http.Error(w, "Failed to update project", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
+
+// handleDeleteProject deletes the project and its ping key, its jobs are kept.
+func (s *Server) handleDeleteProject(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSession(w, r) {
+		return
+	}
+
+	project, ok := s.getOwnedProject(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.db.DeleteProject(project.ID); err != nil {
+		s.logger.Printf("Error deleting project: %v", err)
+		http.Error(w, "Failed to delete project", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleRotatePingKey creates the ping key of the project, or replaces it.
+// The response is the only time the key is shown.
+func (s *Server) handleRotatePingKey(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSession(w, r) {
+		return
+	}
+
+	project, ok := s.getOwnedProject(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.db.RotatePingKey(project); err != nil {
+		s.logger.Printf("Error rotating ping key: %v", err)
+		http.Error(w, "Failed to rotate ping key", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
+
+func (s *Server) handleDeletePingKey(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSession(w, r) {
+		return
+	}
+
+	project, ok := s.getOwnedProject(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.db.DeletePingKey(project.ID); err != nil {
+		s.logger.Printf("Error deleting ping key: %v", err)
+		http.Error(w, "Failed to delete ping key", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// getOwnedProject loads the project named by the id path value and writes an
+// error response when it does not exist or belongs to another user.
+func (s *Server) getOwnedProject(w http.ResponseWriter, r *http.Request) (*models.Project, bool) {
+	project, err := s.db.GetProject(r.PathValue("id"))
+	if err != nil {
+		s.logger.Printf("Error getting project: %v", err)
+		http.Error(w, "Failed to get project", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if project == nil {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	if project.UserID != userIDFrom(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+
+	return project, true
+}
+
+// checkProject verifies that a job may be put in the project, and writes an
+// error response when it may not. A nil project ID is always fine.
+func (s *Server) checkProject(w http.ResponseWriter, projectID *string, userID string) bool {
+	if projectID == nil {
+		return true
+	}
+
+	project, err := s.db.GetProject(*projectID)
+	if err != nil {
+		s.logger.Printf("Error getting project: %v", err)
+		http.Error(w, "Failed to get project", http.StatusInternalServerError)
+		return false
+	}
+
+	if project == nil || project.UserID != userID {
+		http.Error(w, "Project not found", http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+// pingJobID returns the job a ping is for, either named by its ID or by the
+// ping key of its project and the slug of the job. With ?create=1, an unknown
+// slug creates the job. It writes an error response when there is no job.
+func (s *Server) pingJobID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	pingKey := r.PathValue("ping_key")
+	if pingKey == "" {
+		id := r.PathValue("id")
+		if id == "" {
+			http.Error(w, "Job ID is required", http.StatusBadRequest)
+			return "", false
+		}
+		return id, true
+	}
+
+	project, err := s.db.GetPingKeyProject(pingKey)
+	if err != nil {
+		s.logger.Printf("Error getting ping key: %v", err)
+		http.Error(w, "Failed to record ping", http.StatusInternalServerError)
+		return "", false
+	}
+
+	if project == nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return "", false
+	}
+
+	slug := r.PathValue("job")
+	job, err := s.db.GetProjectJob(project.ID, slug)
+	if err != nil {
+		s.logger.Printf("Error getting job: %v", err)
+		http.Error(w, "Failed to record ping", http.StatusInternalServerError)
+		return "", false
+	}
+
+	if job != nil {
+		return job.ID, true
+	}
+
+	if r.URL.Query().Get("create") != "1" || !models.ValidSlug(slug) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return "", false
+	}
+
+	job, err = s.createPingJob(project, slug)
+	if err != nil {
+		if errors.Is(err, db.ErrJobLimit) {
+			http.Error(w, "Job limit reached", http.StatusForbidden)
+			return "", false
+		}
+		if errors.Is(err, db.ErrSlugTaken) {
+			http.Error(w, "Slug is already used by a job outside of this project", http.StatusConflict)
+			return "", false
+		}
+		s.logger.Printf("Error creating job: %v", err)
+		http.Error(w, "Failed to create job", http.StatusInternalServerError)
+		return "", false
+	}
+
+	return job.ID, true
+}
+
+// createPingJob creates a job in the project for a slug that was pinged before
+// it existed. It expects a ping every day, which can be changed once the job
+// shows up.
+func (s *Server) createPingJob(project *models.Project, slug string) (*models.Job, error) {
+	now := time.Now().UTC()
+	job := &models.Job{
+		ID:          uuid.New().String(),
+		Name:        slug,
+		Slug:        slug,
+		Kind:        models.KindPeriod,
+		Period:      models.Duration(24 * time.Hour),
+		Timezone:    "UTC",
+		GracePeriod: defaultGracePeriod,
+		Status:      models.StatusHealthy,
+		LastPing:    now,
+		UserID:      project.UserID,
+		ProjectID:   &project.ID,
+	}
+
+	nextExpect, err := schedule.NextExpect(job, now)
+	if err != nil {
+		return nil, fmt.Errorf("error calculating next tick: %w", err)
+	}
+	job.NextExpect = nextExpect
+
+	err = s.db.CreateJob(job)
+	if errors.Is(err, db.ErrSlugTaken) {
+		// created by a concurrent ping, unless another job of the user has it
+		job, err = s.db.GetProjectJob(project.ID, slug)
+		if err == nil && job == nil {
+			err = db.ErrSlugTaken
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
@@ -2,44 +2,137 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/adhocore/gronx"
 	"github.com/google/uuid"
 	"github.com/zigamedved/cronsentry/internal/db"
 	"github.com/zigamedved/cronsentry/internal/models"
+	"github.com/zigamedved/cronsentry/internal/notifications"
+	"github.com/zigamedved/cronsentry/internal/schedule"
+	"github.com/zigamedved/cronsentry/internal/sso"
+	"github.com/zigamedved/cronsentry/internal/templates"
 )
 
+// maxPingBodySize is how much of a ping request body is kept with the event.
+const maxPingBodySize = 10 * 1024
+
+const defaultGracePeriod = models.Duration(10 * time.Minute)
+
+// defaultMaxReminders caps reminders when only the interval is set.
+const defaultMaxReminders = 5
+
+const slugError = "Slug must be lowercase letters and digits separated by dashes, at most 100 characters"
+
 type Server struct {
-	db     *db.Database
-	logger *log.Logger
+	db        *db.Database
+	notifiers *notifications.Registry
+	templates *templates.Renderer
+	sso       *sso.Provider // nil when SSO is not configured
+	logger    *log.Logger
+
+	// allowRegistration lets anyone sign up, otherwise only the first
+	// account can be registered
+	allowRegistration bool
+
+	// dashboardURL is where SSO logins return to. Its origin may send the
+	// session cookie along with cross origin requests.
+	dashboardURL    string
+	dashboardOrigin string
 }
 
-func NewServer(database *db.Database, logger *log.Logger) *Server {
+func NewServer(database *db.Database, notifiers *notifications.Registry, renderer *templates.Renderer, provider *sso.Provider, allowRegistration bool, dashboardURL string, logger *log.Logger) *Server {
 	return &Server{
-		db:     database,
-		logger: logger,
+		db:                database,
+		notifiers:         notifiers,
+		templates:         renderer,
+		sso:               provider,
+		logger:            logger,
+		allowRegistration: allowRegistration,
+		dashboardURL:      dashboardURL,
+		dashboardOrigin:   origin(dashboardURL),
 	}
 }
 
 func (s *Server) Router() http.Handler {
+	// everything under /api/ needs a session or an API key, except for the
+	// routes registered on mux directly
+	api := http.NewServeMux()
+	api.HandleFunc("GET /api/auth/me", s.handleMe)
+	api.HandleFunc("GET /api/auth/oidc/link", s.handleSSOLink)
+	api.HandleFunc("GET /api/teams", s.handleListTeams)
+	api.HandleFunc("POST /api/keys", s.handleCreateAPIKey)
+	api.HandleFunc("GET /api/keys", s.handleListAPIKeys)
+	api.HandleFunc("DELETE /api/keys/{id}", s.handleDeleteAPIKey)
+	api.HandleFunc("POST /api/projects", s.handleCreateProject)
+	api.HandleFunc("GET /api/projects", s.handleListProjects)
+	api.HandleFunc("PUT /api/projects/{id}", s.handleUpdateProject)
+	api.HandleFunc("DELETE /api/projects/{id}", s.handleDeleteProject)
+	api.HandleFunc("POST /api/projects/{id}/ping-key", s.handleRotatePingKey)
+	api.HandleFunc("DELETE /api/projects/{id}/ping-key", s.handleDeletePingKey)
+	api.HandleFunc("POST /api/jobs", s.handleCreateJob)
+	api.HandleFunc("GET /api/jobs", s.handleListJobs)
+	api.HandleFunc("GET /api/jobs/{id}", s.handleGetJob)
+	api.HandleFunc("PUT /api/jobs/{id}", s.handleUpdateJob)
+	api.HandleFunc("DELETE /api/jobs/{id}", s.handleDeleteJob)
+	api.HandleFunc("POST /api/jobs/{id}/ack", s.handleAckJob)
+	api.HandleFunc("GET /api/jobs/{id}/runs", s.handleListRuns)
+	api.HandleFunc("GET /api/jobs/{id}/events", s.handleListJobEvents)
+	api.HandleFunc("GET /api/jobs/{id}/events/{event_id}", s.handleGetEvent)
+	api.HandleFunc("GET /api/events", s.handleListEvents)
+	api.HandleFunc("POST /api/channels", s.handleCreateChannel)
+	api.HandleFunc("GET /api/channels", s.handleListChannels)
+	api.HandleFunc("PUT /api/channels/{id}", s.handleUpdateChannel)
+	api.HandleFunc("DELETE /api/channels/{id}", s.handleDeleteChannel)
+	api.HandleFunc("POST /api/escalation-policies", s.handleCreatePolicy)
+	api.HandleFunc("GET /api/escalation-policies", s.handleListPolicies)
+	api.HandleFunc("PUT /api/escalation-policies/{id}", s.handleUpdatePolicy)
+	api.HandleFunc("DELETE /api/escalation-policies/{id}", s.handleDeletePolicy)
+	api.HandleFunc("GET /api/templates", s.handleListTemplates)
+	api.HandleFunc("PUT /api/templates", s.handleSaveTemplate)
+	api.HandleFunc("DELETE /api/templates/{id}", s.handleDeleteTemplate)
+	api.HandleFunc("POST /api/templates/preview", s.handlePreviewTemplate)
+	api.HandleFunc("GET /api/notifications/dead", s.handleListDeadLetters)
+	api.HandleFunc("POST /api/notifications/{id}/retry", s.handleRetryNotification)
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("POST /api/jobs", s.handleCreateJob)
-	mux.HandleFunc("GET /api/jobs", s.handleListJobs)
-	mux.HandleFunc("GET /api/jobs/{id}", s.handleGetJob)
-	mux.HandleFunc("PUT /api/jobs/{id}", s.handleUpdateJob)
-	mux.HandleFunc("DELETE /api/jobs/{id}", s.handleDeleteJob)
+	mux.Handle("/api/", s.requireAuth(api))
+	mux.HandleFunc("GET /api/auth/register", s.handleRegistrationConfig)
+	mux.HandleFunc("POST /api/auth/register", s.handleRegister)
+	mux.HandleFunc("POST /api/auth/login", s.handleLogin)
+	mux.HandleFunc("POST /api/auth/logout", s.handleLogout)
+	mux.HandleFunc("GET /api/auth/oidc", s.handleSSOConfig)
+	mux.HandleFunc("GET /api/auth/oidc/login", s.handleSSOLogin)
+	mux.HandleFunc("GET /api/auth/oidc/callback", s.handleSSOCallback)
 	mux.HandleFunc("POST /api/ping/{id}", s.handlePing)
+	mux.HandleFunc("POST /api/ping/{id}/start", s.handlePingStart)
+	mux.HandleFunc("POST /api/ping/{id}/fail", s.handlePingFail)
+	mux.HandleFunc("POST /api/ping/{id}/{exit_code}", s.handlePingExitCode)
+	mux.HandleFunc("POST /ping/{ping_key}/{job}", s.handlePing)
+	mux.HandleFunc("POST /ping/{ping_key}/{job}/start", s.handlePingStart)
+	mux.HandleFunc("POST /ping/{ping_key}/{job}/fail", s.handlePingFail)
+	mux.HandleFunc("POST /ping/{ping_key}/{job}/{exit_code}", s.handlePingExitCode)
 	return s.corsMiddleware(s.loggingMiddleware(s.recoveryMiddleware(mux)))
 }
 
 func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if s.dashboardOrigin != "" && r.Header.Get("Origin") == s.dashboardOrigin {
+			w.Header().Set("Access-Control-Allow-Origin", s.dashboardOrigin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+		w.Header().Set("Vary", "Origin")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -50,12 +143,33 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// origin returns the scheme and host of a URL, the way browsers send it in
+// the Origin header.
+func origin(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
 func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
 	var jobRequest struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-		Schedule    string `json:"schedule"`
-		GraceTime   int    `json:"grace_time"`
+		Name        string          `json:"name"`
+		Slug        string          `json:"slug"` // generated from the name when empty
+		Description string          `json:"description"`
+		Kind        models.JobKind  `json:"kind"`
+		Schedule    string          `json:"schedule"`
+		Period      models.Duration `json:"period"`
+		Timezone    string          `json:"timezone"`
+		GracePeriod models.Duration `json:"grace_period"`
+		GraceTime   int             `json:"grace_time"` // minutes, superseded by grace_period
+		MaxRuntime  models.Duration `json:"max_runtime"`
+		PolicyID    *string         `json:"escalation_policy_id"`
+		ProjectID   *string         `json:"project_id"`
+
+		ReminderInterval models.Duration `json:"reminder_interval"`
+		MaxReminders     int             `json:"max_reminders"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&jobRequest); err != nil {
@@ -64,40 +178,98 @@ func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if jobRequest.Name == "" || jobRequest.Schedule == "" {
-		s.logger.Println("Name and schedule are required")
-		http.Error(w, "Name and schedule are required", http.StatusBadRequest)
+	if jobRequest.Name == "" {
+		s.logger.Println("Name is required")
+		http.Error(w, "Name is required", http.StatusBadRequest)
 		return
 	}
 
-	if !gronx.IsValid(jobRequest.Schedule) {
-		s.logger.Println("Invalid CRON schedule provided")
-		http.Error(w, "Invalid CRON schedule provided", http.StatusBadRequest)
+	if jobRequest.Slug != "" && !models.ValidSlug(jobRequest.Slug) {
+		http.Error(w, slugError, http.StatusBadRequest)
 		return
 	}
 
-	nextTick, err := gronx.NextTick(jobRequest.Schedule, true)
-	if err != nil {
-		s.logger.Println("Error calculating next tick")
-		http.Error(w, "Error calculating next tick", http.StatusBadRequest)
+	if jobRequest.GracePeriod < 0 || jobRequest.GraceTime < 0 || jobRequest.MaxRuntime < 0 {
+		http.Error(w, "Durations must not be negative", http.StatusBadRequest)
 		return
 	}
+	if jobRequest.GracePeriod == 0 && jobRequest.GraceTime == 0 {
+		jobRequest.GracePeriod = defaultGracePeriod
+	}
+
+	if !validReminders(jobRequest.ReminderInterval, jobRequest.MaxReminders) {
+		http.Error(w, "reminder_interval must be at least 1m and max_reminders must not be negative", http.StatusBadRequest)
+		return
+	}
+	if jobRequest.ReminderInterval > 0 && jobRequest.MaxReminders == 0 {
+		jobRequest.MaxReminders = defaultMaxReminders
+	}
+
+	if jobRequest.PolicyID != nil && *jobRequest.PolicyID == "" {
+		jobRequest.PolicyID = nil
+	}
+	if !s.checkPolicy(w, jobRequest.PolicyID, userIDFrom(r)) {
+		return
+	}
+	if jobRequest.ProjectID != nil && *jobRequest.ProjectID == "" {
+		jobRequest.ProjectID = nil
+	}
+	if !s.checkProject(w, jobRequest.ProjectID, userIDFrom(r)) {
+		return
+	}
+
+	if jobRequest.Kind == "" {
+		jobRequest.Kind = models.KindCron
+	}
+	if jobRequest.Timezone == "" {
+		jobRequest.Timezone = "UTC"
+	}
 
 	job := &models.Job{
 		ID:          uuid.New().String(),
 		Name:        jobRequest.Name,
+		Slug:        jobRequest.Slug,
 		Description: jobRequest.Description,
+		Kind:        jobRequest.Kind,
 		Schedule:    jobRequest.Schedule,
-		GraceTime:   jobRequest.GraceTime,
+		Period:      jobRequest.Period,
+		Timezone:    jobRequest.Timezone,
+		GracePeriod: gracePeriod(jobRequest.GracePeriod, jobRequest.GraceTime),
+		MaxRuntime:  jobRequest.MaxRuntime,
 		Status:      models.StatusHealthy,
 		LastPing:    time.Now().UTC(),
-		NextExpect:  nextTick,
-		UserID:      "test-user", // hardcoded for now, should come from auth
+		UserID:      userIDFrom(r),
 		CreatedAt:   time.Now().UTC(),
 		UpdatedAt:   time.Now().UTC(),
 	}
+	job.EscalationPolicyID = jobRequest.PolicyID
+	job.ProjectID = jobRequest.ProjectID
+	job.ReminderInterval = jobRequest.ReminderInterval
+	job.MaxReminders = jobRequest.MaxReminders
+
+	if err := schedule.Validate(job); err != nil {
+		s.logger.Println(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	nextExpect, err := schedule.NextExpect(job, time.Now())
+	if err != nil {
+		s.logger.Println("Error calculating next tick")
+		http.Error(w, "Error calculating next tick", http.StatusBadRequest)
+		return
+	}
+	job.NextExpect = nextExpect
 
 	if err := s.db.CreateJob(job); err != nil {
+		if errors.Is(err, db.ErrSlugTaken) {
+			http.Error(w, "Slug is already used by another job", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, db.ErrJobLimit) {
+			http.Error(w, "Job limit reached", http.StatusForbidden)
+			return
+		}
 		s.logger.Printf("Error creating job: %v", err)
 		http.Error(w, "Failed to create job", http.StatusInternalServerError)
 		return
@@ -108,8 +280,27 @@ func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(job)
 }
 
+// gracePeriod prefers grace_period over the older grace_time. Unlike
+// grace_period, which is a duration string or a number of seconds, grace_time
+// is a number of minutes.
+func gracePeriod(period models.Duration, minutes int) models.Duration {
+	if period > 0 {
+		return period
+	}
+	return models.Duration(time.Duration(minutes) * time.Minute)
+}
+
+// validReminders reports whether the reminder settings are usable. An
+// interval of 0 disables reminders.
+func validReminders(interval models.Duration, max int) bool {
+	if interval < 0 || max < 0 {
+		return false
+	}
+	return interval == 0 || interval.Duration() >= time.Minute
+}
+
 func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
-	userID := "test-user" // TODO: get user ID from auth
+	userID := userIDFrom(r)
 
 	jobs, err := s.db.ListJobsByUser(userID)
 	if err != nil {
@@ -146,8 +337,8 @@ func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if job.UserID != "test-user" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if job.UserID != userIDFrom(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
@@ -174,17 +365,28 @@ func (s *Server) handleUpdateJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if job.UserID != "test-user" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if job.UserID != userIDFrom(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
 	var jobRequest struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-		Schedule    string `json:"schedule"`
-		GraceTime   int    `json:"grace_time"`
-		Status      string `json:"status"`
+		Name        string           `json:"name"`
+		Slug        string           `json:"slug"`
+		Description string           `json:"description"`
+		Kind        models.JobKind   `json:"kind"`
+		Schedule    string           `json:"schedule"`
+		Period      models.Duration  `json:"period"`
+		Timezone    string           `json:"timezone"`
+		GracePeriod models.Duration  `json:"grace_period"`
+		GraceTime   int              `json:"grace_time"` // minutes, superseded by grace_period
+		MaxRuntime  *models.Duration `json:"max_runtime"`
+		PolicyID    *string          `json:"escalation_policy_id"` // empty to remove the policy
+		ProjectID   *string          `json:"project_id"`           // empty to remove the job from its project
+		Status      string           `json:"status"`
+
+		ReminderInterval *models.Duration `json:"reminder_interval"`
+		MaxReminders     *int             `json:"max_reminders"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&jobRequest); err != nil {
@@ -195,20 +397,96 @@ func (s *Server) handleUpdateJob(w http.ResponseWriter, r *http.Request) {
 	if jobRequest.Name != "" {
 		job.Name = jobRequest.Name
 	}
+	if jobRequest.Slug != "" {
+		if !models.ValidSlug(jobRequest.Slug) {
+			http.Error(w, slugError, http.StatusBadRequest)
+			return
+		}
+		job.Slug = jobRequest.Slug
+	}
 	if jobRequest.Description != "" {
 		job.Description = jobRequest.Description
 	}
-	if jobRequest.Schedule != "" {
-		job.Schedule = jobRequest.Schedule
+	if jobRequest.Kind != "" || jobRequest.Schedule != "" || jobRequest.Period > 0 || jobRequest.Timezone != "" {
+		if jobRequest.Kind != "" {
+			job.Kind = jobRequest.Kind
+		}
+		if jobRequest.Schedule != "" {
+			job.Schedule = jobRequest.Schedule
+		}
+		if jobRequest.Period > 0 {
+			job.Period = jobRequest.Period
+		}
+		if jobRequest.Timezone != "" {
+			job.Timezone = jobRequest.Timezone
+		}
+
+		if err := schedule.Validate(job); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		nextExpect, err := schedule.NextExpect(job, time.Now())
+		if err != nil {
+			http.Error(w, "Error calculating next tick", http.StatusBadRequest)
+			return
+		}
+		job.NextExpect = nextExpect
+	}
+	if jobRequest.GracePeriod < 0 || jobRequest.GraceTime < 0 {
+		http.Error(w, "Durations must not be negative", http.StatusBadRequest)
+		return
+	}
+	if jobRequest.GracePeriod > 0 || jobRequest.GraceTime > 0 {
+		job.GracePeriod = gracePeriod(jobRequest.GracePeriod, jobRequest.GraceTime)
 	}
-	if jobRequest.GraceTime > 0 {
-		job.GraceTime = jobRequest.GraceTime
+	if jobRequest.MaxRuntime != nil {
+		if *jobRequest.MaxRuntime < 0 {
+			http.Error(w, "Durations must not be negative", http.StatusBadRequest)
+			return
+		}
+		job.MaxRuntime = *jobRequest.MaxRuntime
+	}
+	if jobRequest.ReminderInterval != nil {
+		job.ReminderInterval = *jobRequest.ReminderInterval
+		if job.ReminderInterval > 0 && job.MaxReminders == 0 {
+			job.MaxReminders = defaultMaxReminders
+		}
+	}
+	if jobRequest.MaxReminders != nil {
+		job.MaxReminders = *jobRequest.MaxReminders
+	}
+	if !validReminders(job.ReminderInterval, job.MaxReminders) {
+		http.Error(w, "reminder_interval must be at least 1m and max_reminders must not be negative", http.StatusBadRequest)
+		return
+	}
+	if jobRequest.PolicyID != nil {
+		job.EscalationPolicyID = jobRequest.PolicyID
+		if *jobRequest.PolicyID == "" {
+			job.EscalationPolicyID = nil
+		}
+		if !s.checkPolicy(w, job.EscalationPolicyID, job.UserID) {
+			return
+		}
+	}
+	if jobRequest.ProjectID != nil {
+		job.ProjectID = jobRequest.ProjectID
+		if *jobRequest.ProjectID == "" {
+			job.ProjectID = nil
+		}
+		if !s.checkProject(w, job.ProjectID, job.UserID) {
+			return
+		}
 	}
 	if jobRequest.Status != "" {
 		job.Status = models.JobStatus(jobRequest.Status)
 	}
 
 	if err := s.db.UpdateJob(job); err != nil {
+		if errors.Is(err, db.ErrSlugTaken) {
+			http.Error(w, "Slug is already used by another job", http.StatusConflict)
+			return
+		}
 		s.logger.Printf("Error updating job: %v", err)
 		http.Error(w, "Failed to update job", http.StatusInternalServerError)
 		return
@@ -219,13 +497,52 @@ func (s *Server) handleUpdateJob(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handlePing(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	if id == "" {
-		http.Error(w, "Job ID is required", http.StatusBadRequest)
+	s.recordPing(w, r, models.Ping{Kind: models.PingSuccess})
+}
+
+func (s *Server) handlePingStart(w http.ResponseWriter, r *http.Request) {
+	s.recordPing(w, r, models.Ping{Kind: models.PingStart})
+}
+
+func (s *Server) handlePingFail(w http.ResponseWriter, r *http.Request) {
+	s.recordPing(w, r, models.Ping{Kind: models.PingFail})
+}
+
+// handlePingExitCode lets wrappers report the exit status of the job
+// directly, e.g. `backup.sh; curl -X POST .../api/ping/$ID/$?`.
+func (s *Server) handlePingExitCode(w http.ResponseWriter, r *http.Request) {
+	exitCode, err := strconv.Atoi(r.PathValue("exit_code"))
+	if err != nil || exitCode < 0 || exitCode > 255 {
+		http.Error(w, "Invalid exit code", http.StatusBadRequest)
 		return
 	}
 
-	if err := s.db.RecordPing(id); err != nil {
+	ping := models.Ping{Kind: models.PingSuccess, ExitCode: &exitCode}
+	if exitCode != 0 {
+		ping.Kind = models.PingFail
+	}
+	s.recordPing(w, r, ping)
+}
+
+func (s *Server) recordPing(w http.ResponseWriter, r *http.Request, ping models.Ping) {
+	id, ok := s.pingJobID(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPingBodySize))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	// Postgres rejects NUL characters in JSONB values
+	ping.Body = strings.ReplaceAll(strings.ToValidUTF8(string(body), "\uFFFD"), "\x00", "")
+
+	if err := s.db.RecordPing(id, ping); err != nil {
+		if errors.Is(err, db.ErrJobNotFound) {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
 		s.logger.Printf("Error recording ping: %v", err)
 		http.Error(w, "Failed to record ping", http.StatusInternalServerError)
 		return
@@ -235,6 +552,186 @@ func (s *Server) handlePing(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"status":"ok"}`))
 }
 
+func (s *Server) handleListJobEvents(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "Job ID is required", http.StatusBadRequest)
+		return
+	}
+
+	job, err := s.db.GetJob(id)
+	if err != nil {
+		s.logger.Printf("Error getting job: %v", err)
+		http.Error(w, "Failed to get job", http.StatusInternalServerError)
+		return
+	}
+
+	if job == nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	if job.UserID != userIDFrom(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	s.listEvents(w, r, id)
+}
+
+func (s *Server) handleListEvents(w http.ResponseWriter, r *http.Request) {
+	s.listEvents(w, r, "")
+}
+
+func (s *Server) listEvents(w http.ResponseWriter, r *http.Request, jobID string) {
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.UserID = userIDFrom(r)
+	filter.JobID = jobID
+
+	events, nextCursor, err := s.db.ListEvents(filter)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidCursor) {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		s.logger.Printf("Error listing events: %v", err)
+		http.Error(w, "Failed to list events", http.StatusInternalServerError)
+		return
+	}
+
+	if events == nil {
+		events = make([]*models.JobEvent, 0)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Events     []*models.JobEvent `json:"events"`
+		NextCursor string             `json:"next_cursor,omitempty"`
+	}{events, nextCursor})
+}
+
+// parseEventFilter reads the type, since, until, cursor and limit query
+// parameters. Types may be repeated or comma separated.
+func parseEventFilter(r *http.Request) (models.EventFilter, error) {
+	query := r.URL.Query()
+	filter := models.EventFilter{
+		Cursor: query.Get("cursor"),
+		Limit:  50,
+	}
+
+	for _, value := range query["type"] {
+		for _, t := range strings.Split(value, ",") {
+			if t != "" {
+				filter.Types = append(filter.Types, models.JobEventType(t))
+			}
+		}
+	}
+
+	for name, dest := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("Invalid %s, expected RFC 3339 time", name)
+			}
+			*dest = &t
+		}
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > 200 {
+			return filter, errors.New("Invalid limit, expected 1-200")
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+func (s *Server) handleGetEvent(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	eventID := r.PathValue("event_id")
+	if id == "" || eventID == "" {
+		http.Error(w, "Job ID and event ID are required", http.StatusBadRequest)
+		return
+	}
+
+	job, err := s.db.GetJob(id)
+	if err != nil {
+		s.logger.Printf("Error getting job: %v", err)
+		http.Error(w, "Failed to get job", http.StatusInternalServerError)
+		return
+	}
+
+	if job == nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	if job.UserID != userIDFrom(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	event, err := s.db.GetEvent(id, eventID)
+	if err != nil {
+		s.logger.Printf("Error getting event: %v", err)
+		http.Error(w, "Failed to get event", http.StatusInternalServerError)
+		return
+	}
+
+	if event == nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+func (s *Server) handleListRuns(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "Job ID is required", http.StatusBadRequest)
+		return
+	}
+
+	job, err := s.db.GetJob(id)
+	if err != nil {
+		s.logger.Printf("Error getting job: %v", err)
+		http.Error(w, "Failed to get job", http.StatusInternalServerError)
+		return
+	}
+
+	if job == nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	if job.UserID != userIDFrom(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	runs, err := s.db.ListRunsByJob(id, 50)
+	if err != nil {
+		s.logger.Printf("Error listing runs: %v", err)
+		http.Error(w, "Failed to list runs", http.StatusInternalServerError)
+		return
+	}
+
+	if runs == nil {
+		runs = make([]*models.JobRun, 0)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
 func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -275,8 +772,8 @@ func (s *Server) handleDeleteJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if job.UserID != "test-user" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if job.UserID != userIDFrom(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zigamedved/cronsentry/internal/db"
+	"github.com/zigamedved/cronsentry/internal/models"
+	"github.com/zigamedved/cronsentry/internal/sso"
+)
+
+// flowCookie keeps the state, nonce and PKCE verifier of an SSO login until
+// the provider redirects back.
+const flowCookie = "cronsentry_oidc"
+
+const flowTTL = 10 * time.Minute
+
+// handleSSOConfig tells the dashboard whether to offer signing in with SSO.
+func (s *Server) handleSSOConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"enabled": s.sso != nil})
+}
+
+// linkFlow marks the flow cookie of a login that links the identity to the
+// signed in user instead of signing in.
+const linkFlow = "link:"
+
+// handleSSOLogin sends the browser to the provider to sign in.
+func (s *Server) handleSSOLogin(w http.ResponseWriter, r *http.Request) {
+	s.startSSO(w, r, false)
+}
+
+// handleSSOLink sends the browser to the provider to link the identity the
+// user signs in with there to their account. Users with a password have to
+// do this before they can sign in with SSO.
+func (s *Server) handleSSOLink(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSession(w, r) {
+		return
+	}
+	s.startSSO(w, r, true)
+}
+
+func (s *Server) startSSO(w http.ResponseWriter, r *http.Request, link bool) {
+	if s.sso == nil {
+		http.Error(w, "SSO is not configured", http.StatusNotFound)
+		return
+	}
+
+	authURL, flow, err := s.sso.Start(r.Context())
+	if err != nil {
+		s.logger.Printf("Error starting SSO login: %v", err)
+		http.Error(w, "Failed to start SSO login", http.StatusBadGateway)
+		return
+	}
+
+	value := flow.Encode()
+	if link {
+		value = linkFlow + value
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     flowCookie,
+		Value:    value,
+		Path:     "/api/auth/oidc",
+		MaxAge:   int(flowTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// handleSSOCallback finishes the login once the provider redirects back. The
+// user is created on the first login, and their teams follow the groups the
+// provider reports on every login.
+func (s *Server) handleSSOCallback(w http.ResponseWriter, r *http.Request) {
+	if s.sso == nil {
+		http.Error(w, "SSO is not configured", http.StatusNotFound)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     flowCookie,
+		Path:     "/api/auth/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		s.logger.Printf("SSO login failed: %s: %s", query.Get("error"), query.Get("error_description"))
+		http.Error(w, "SSO login failed", http.StatusUnauthorized)
+		return
+	}
+
+	cookie, err := r.Cookie(flowCookie)
+	if err != nil {
+		http.Error(w, "SSO login expired, please try again", http.StatusBadRequest)
+		return
+	}
+
+	value, link := strings.CutPrefix(cookie.Value, linkFlow)
+	flow, err := sso.DecodeFlow(value)
+	if err != nil || subtle.ConstantTimeCompare([]byte(flow.State), []byte(query.Get("state"))) != 1 {
+		http.Error(w, "Invalid SSO login state", http.StatusBadRequest)
+		return
+	}
+
+	identity, err := s.sso.Exchange(r.Context(), query.Get("code"), flow)
+	if err != nil {
+		s.logger.Printf("Error finishing SSO login: %v", err)
+		http.Error(w, "SSO login failed", http.StatusUnauthorized)
+		return
+	}
+
+	if link {
+		s.linkIdentity(w, r, identity)
+		return
+	}
+
+	if identity.Email == "" {
+		http.Error(w, "SSO provider did not return an email address", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.db.ProvisionUser(identity.Issuer, identity.Subject, identity.Email, identity.Name, identity.EmailVerified)
+	if err != nil {
+		if errors.Is(err, db.ErrEmailTaken) {
+			http.Error(w, "An account with this email already exists, log in with its password and link SSO from there", http.StatusConflict)
+			return
+		}
+		s.logger.Printf("Error provisioning user: %v", err)
+		http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.db.SyncTeams(user.ID, s.sso.Teams(identity)); err != nil {
+		s.logger.Printf("Error syncing teams: %v", err)
+		http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+
+	if !s.startSession(w, r, user.ID) {
+		return
+	}
+
+	http.Redirect(w, r, s.dashboardURL, http.StatusFound)
+}
+
+// linkIdentity finishes linking the identity to the user of the session the
+// link was started from. The teams of the user follow its groups from then on.
+func (s *Server) linkIdentity(w http.ResponseWriter, r *http.Request, identity *sso.Identity) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := s.db.GetSessionUserID(cookie.Value)
+	if err != nil {
+		s.logger.Printf("Error getting session: %v", err)
+		http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+		return
+	}
+
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := s.db.LinkIdentity(userID, identity.Issuer, identity.Subject); err != nil {
+		if errors.Is(err, db.ErrIdentityTaken) {
+			http.Error(w, "This SSO account is already linked to another user", http.StatusConflict)
+			return
+		}
+		s.logger.Printf("Error linking identity: %v", err)
+		http.Error(w, "Failed to link SSO account", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.db.SyncTeams(userID, s.sso.Teams(identity)); err != nil {
+		s.logger.Printf("Error syncing teams: %v", err)
+		http.Error(w, "Failed to link SSO account", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, s.dashboardURL, http.StatusFound)
+}
+
+func (s *Server) handleListTeams(w http.ResponseWriter, r *http.Request) {
+	memberships, err := s.db.ListTeamMemberships(userIDFrom(r))
+	if err != nil {
+		s.logger.Printf("Error listing teams: %v", err)
+		http.Error(w, "Failed to list teams", http.StatusInternalServerError)
+		return
+	}
+
+	if memberships == nil {
+		memberships = make([]*models.TeamMembership, 0)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(memberships)
+}
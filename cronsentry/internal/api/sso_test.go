@@ -0,0 +1,269 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/zigamedved/cronsentry/internal/db"
+	"github.com/zigamedved/cronsentry/internal/models"
+	"github.com/zigamedved/cronsentry/internal/sso"
+)
+
+const (
+	testClientID = "cronsentry"
+	testCode     = "code-1"
+	testSubject  = "subject-1"
+	testEmail    = "you@example.com"
+	dashboardURL = "http://dashboard.test"
+)
+
+var userColumns = []string{"id", "email", "name", "password_hash", "created_at", "updated_at"}
+
+// fakeIssuer is an OpenID Connect provider that signs in one user. It checks
+// the PKCE verifier of the code exchange and puts the nonce of the
+// authorization request into the ID token.
+type fakeIssuer struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &fakeIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                issuer.URL,
+			"authorization_endpoint":                issuer.URL + "/authorize",
+			"token_endpoint":                        issuer.URL + "/token",
+			"jwks_uri":                              issuer.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+			Key: &key.PublicKey, KeyID: "key-1", Algorithm: string(jose.RS256), Use: "sig",
+		}}})
+	})
+	mux.HandleFunc("POST /token", issuer.handleToken)
+
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+func (fi *fakeIssuer) handleToken(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if r.PostForm.Get("code") != testCode || base64.RawURLEncoding.EncodeToString(sum[:]) != fi.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: fi.key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "key-1"),
+	)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	claims, _ := json.Marshal(map[string]any{
+		"iss":            fi.URL,
+		"sub":            testSubject,
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          fi.nonce,
+		"email":          "You@example.com",
+		"email_verified": true,
+		"name":           "You",
+		"groups":         []string{"ops", "dev"},
+	})
+	signed, err := signer.Sign(claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	idToken, _ := signed.CompactSerialize()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// ssoLogin signs in through the fake issuer and returns the response of the
+// callback.
+func ssoLogin(t *testing.T, issuer *fakeIssuer, database *db.Database) *httptest.ResponseRecorder {
+	provider := sso.NewProvider(sso.Config{
+		Issuer:      issuer.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://api.test/api/auth/oidc/callback",
+		GroupMapping: sso.GroupMapping{
+			"ops": {{Team: "platform", Role: models.RoleAdmin}},
+			"dev": {{Team: "platform", Role: models.RoleMember}},
+			"qa":  {{Team: "testing", Role: models.RoleMember}},
+		},
+	})
+	router := NewServer(database, nil, nil, provider, false, dashboardURL, log.New(io.Discard, "", 0)).Router()
+
+	login := httptest.NewRecorder()
+	router.ServeHTTP(login, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+	if login.Code != http.StatusFound {
+		t.Fatalf("login status = %d, want %d: %s", login.Code, http.StatusFound, login.Body)
+	}
+
+	authURL, err := url.Parse(login.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer.challenge = authURL.Query().Get("code_challenge")
+	issuer.nonce = authURL.Query().Get("nonce")
+
+	callback := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?"+url.Values{
+		"code":  {testCode},
+		"state": {authURL.Query().Get("state")},
+	}.Encode(), nil)
+	for _, cookie := range login.Result().Cookies() {
+		callback.AddCookie(cookie)
+	}
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, callback)
+	return response
+}
+
+// rowsFromArg adds a user row to rows once the ID the user is created with
+// is known, so the queries after the insert return the new user.
+type rowsFromArg []*sqlmock.Rows
+
+func (r rowsFromArg) Match(v driver.Value) bool {
+	now := time.Now().UTC()
+	for _, rows := range r {
+		rows.AddRow(v, testEmail, "You", "!", now, now)
+	}
+	return true
+}
+
+func TestSSOCallbackProvisionsUser(t *testing.T) {
+	issuer := newFakeIssuer(t)
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	created := sqlmock.NewRows(userColumns)
+	linked := sqlmock.NewRows(userColumns)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM user_identities`).WithArgs(issuer.URL, testSubject).WillReturnRows(sqlmock.NewRows(userColumns))
+	mock.ExpectExec(`INSERT INTO users`).
+		WithArgs(rowsFromArg{created, linked}, testEmail, "You", "!", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`FROM users WHERE email = \$1 FOR UPDATE`).WithArgs(testEmail).WillReturnRows(created)
+	mock.ExpectQuery(`FROM user_identities`).WithArgs(issuer.URL, testSubject).WillReturnRows(sqlmock.NewRows(userColumns))
+	mock.ExpectExec(`INSERT INTO user_identities`).
+		WithArgs(issuer.URL, testSubject, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`FROM user_identities`).WithArgs(issuer.URL, testSubject).WillReturnRows(linked)
+	mock.ExpectCommit()
+	// the groups of the user map to one team, with the highest role
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO teams`).WithArgs(sqlmock.AnyArg(), "platform", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT id FROM teams`).WithArgs("platform").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("team-1"))
+	mock.ExpectExec(`INSERT INTO team_members`).WithArgs("team-1", sqlmock.AnyArg(), models.RoleAdmin).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM team_members`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM sessions`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO sessions`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	response := ssoLogin(t, issuer, db.NewDatabaseFromDB(sqlDB))
+
+	if response.Code != http.StatusFound || response.Header().Get("Location") != dashboardURL {
+		t.Fatalf("callback = %d to %q, want %d to %s: %s", response.Code, response.Header().Get("Location"), http.StatusFound, dashboardURL, response.Body)
+	}
+
+	var session *http.Cookie
+	for _, cookie := range response.Result().Cookies() {
+		if cookie.Name == sessionCookie {
+			session = cookie
+		}
+	}
+	if session == nil || session.Value == "" {
+		t.Error("callback did not start a session")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSSOCallbackRefusesPasswordAccount(t *testing.T) {
+	issuer := newFakeIssuer(t)
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	now := time.Now().UTC()
+	existing := sqlmock.NewRows(userColumns).
+		AddRow("user-1", testEmail, "You", "$2a$10$abcdefghijklmnopqrstuvABCDEFGHIJKLMNOPQRSTUVWXYZ01234", now, now)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM user_identities`).WithArgs(issuer.URL, testSubject).WillReturnRows(sqlmock.NewRows(userColumns))
+	mock.ExpectExec(`INSERT INTO users`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`FROM users WHERE email = \$1 FOR UPDATE`).WithArgs(testEmail).WillReturnRows(existing)
+	mock.ExpectQuery(`FROM user_identities`).WithArgs(issuer.URL, testSubject).WillReturnRows(sqlmock.NewRows(userColumns))
+	mock.ExpectRollback()
+
+	response := ssoLogin(t, issuer, db.NewDatabaseFromDB(sqlDB))
+
+	if response.Code != http.StatusConflict {
+		t.Fatalf("callback status = %d, want %d: %s", response.Code, http.StatusConflict, response.Body)
+	}
+	for _, cookie := range response.Result().Cookies() {
+		if cookie.Name == sessionCookie {
+			t.Error("callback started a session for the password account")
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
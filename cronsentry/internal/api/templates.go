@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/zigamedved/cronsentry/internal/models"
+	"github.com/zigamedved/cronsentry/internal/templates"
+)
+
+func (s *Server) handleListTemplates(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFrom(r)
+
+	list, err := s.db.ListTemplatesByUser(userID)
+	if err != nil {
+		s.logger.Printf("Error listing templates: %v", err)
+		http.Error(w, "Failed to list templates", http.StatusInternalServerError)
+		return
+	}
+
+	if list == nil {
+		list = make([]*models.Template, 0)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func (s *Server) handleSaveTemplate(w http.ResponseWriter, r *http.Request) {
+	var templateRequest struct {
+		Channel   string              `json:"channel"`
+		EventType models.JobEventType `json:"event_type"`
+		Part      templates.Part      `json:"part"`
+		Body      string              `json:"body"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&templateRequest); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.validateTemplateTarget(templateRequest.Channel, templateRequest.EventType, templateRequest.Part); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// rendering the sample data also catches templates that parse but refer
+	// to fields that do not exist
+	overrides := templates.Overrides{templateRequest.Part: templateRequest.Body}
+	sample := templates.SampleData(templateRequest.EventType)
+	if _, err := s.templates.Render(templateRequest.Channel, templateRequest.EventType, overrides, sample); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	template := &models.Template{
+		UserID:    userIDFrom(r),
+		Channel:   templateRequest.Channel,
+		EventType: templateRequest.EventType,
+		Part:      string(templateRequest.Part),
+		Body:      templateRequest.Body,
+	}
+
+	if err := s.db.SaveTemplate(template); err != nil {
+		s.logger.Printf("Error saving template: %v", err)
+		http.Error(w, "Failed to save template", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(template)
+}
+
+func (s *Server) handleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	template, err := s.db.GetTemplate(r.PathValue("id"))
+	if err != nil {
+		s.logger.Printf("Error getting template: %v", err)
+		http.Error(w, "Failed to get template", http.StatusInternalServerError)
+		return
+	}
+
+	if template == nil {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	if template.UserID != userIDFrom(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if err := s.db.DeleteTemplate(template.ID); err != nil {
+		s.logger.Printf("Error deleting template: %v", err)
+		http.Error(w, "Failed to delete template", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handlePreviewTemplate renders all parts of a channel against sample data,
+// using the saved overrides of the user and optionally an unsaved body for
+// one part.
+func (s *Server) handlePreviewTemplate(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFrom(r)
+
+	var previewRequest struct {
+		Channel   string              `json:"channel"`
+		EventType models.JobEventType `json:"event_type"`
+		Part      templates.Part      `json:"part"`
+		Body      *string             `json:"body"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&previewRequest); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	part := previewRequest.Part
+	if part == "" {
+		part = templates.PartMessage
+	}
+
+	if err := s.validateTemplateTarget(previewRequest.Channel, previewRequest.EventType, part); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saved, err := s.db.ListTemplatesByUser(userID)
+	if err != nil {
+		s.logger.Printf("Error listing templates: %v", err)
+		http.Error(w, "Failed to list templates", http.StatusInternalServerError)
+		return
+	}
+
+	overrides := make(templates.Overrides)
+	for _, t := range saved {
+		if t.Channel == previewRequest.Channel && t.EventType == previewRequest.EventType {
+			overrides[templates.Part(t.Part)] = t.Body
+		}
+	}
+	if previewRequest.Body != nil {
+		overrides[part] = *previewRequest.Body
+	}
+
+	sample := templates.SampleData(previewRequest.EventType)
+	content, err := s.templates.Render(previewRequest.Channel, previewRequest.EventType, overrides, sample)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(content)
+}
+
+func (s *Server) validateTemplateTarget(channel string, eventType models.JobEventType, part templates.Part) error {
+	if _, ok := s.notifiers.Get(channel); !ok {
+		return fmt.Errorf("unsupported channel type %q", channel)
+	}
+	if !slices.Contains(templates.EventTypes, eventType) {
+		return fmt.Errorf("unsupported event type %q", eventType)
+	}
+	if !slices.Contains(templates.PartsFor(channel), part) {
+		return fmt.Errorf("channel %s has no %q template", channel, part)
+	}
+	return nil
+}
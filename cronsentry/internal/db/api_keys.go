@@ -0,0 +1,137 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+// apiKeyPrefix marks API keys, so they are easy to spot in scripts and by
+// secret scanners.
+const apiKeyPrefix = "cs_"
+
+const apiKeyColumns = `id, user_id, name, prefix, expires_at, last_used_at, created_at`
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.ExpiresAt, &key.LastUsedAt, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (d *Database) GetAPIKey(id string) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE id = $1
+	`
+
+	key, err := scanAPIKey(d.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error querying API key: %w", err)
+	}
+
+	return key, nil
+}
+
+func (d *Database) ListAPIKeysByUser(userID string) ([]*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+	rows, err := d.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning API key row: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating API key rows: %w", err)
+	}
+
+	return keys, nil
+}
+
+// CreateAPIKey generates a new key and sets key.Key. Only its hash is stored,
+// so this is the only time the key can be shown.
+func (d *Database) CreateAPIKey(key *models.APIKey) error {
+	if key.ID == "" {
+		key.ID = uuid.New().String()
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	key.Key = apiKeyPrefix + token
+	key.Prefix = key.Key[:len(apiKeyPrefix)+6]
+	key.CreatedAt = time.Now().UTC()
+
+	query := `
+		INSERT INTO api_keys (id, user_id, name, prefix, key_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err = d.db.Exec(query, key.ID, key.UserID, key.Name, key.Prefix, hashToken(key.Key), key.ExpiresAt, key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating API key: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) DeleteAPIKey(id string) error {
+	query := `DELETE FROM api_keys WHERE id = $1`
+	result, err := d.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("error deleting API key: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("API key not found")
+	}
+
+	return nil
+}
+
+// UseAPIKey returns the ID and user of an unexpired API key and records that
+// it was used. Both are empty when the key is unknown or expired.
+func (d *Database) UseAPIKey(key string) (string, string, error) {
+	var id, userID string
+	err := d.db.QueryRow(`
+		UPDATE api_keys
+		SET last_used_at = $2
+		WHERE key_hash = $1 AND (expires_at IS NULL OR expires_at > $2)
+		RETURNING id, user_id
+	`, hashToken(key), time.Now().UTC()).Scan(&id, &userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", "", nil
+		}
+		return "", "", fmt.Errorf("error using API key: %w", err)
+	}
+
+	return id, userID, nil
+}
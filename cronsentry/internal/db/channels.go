@@ -0,0 +1,193 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+const channelColumns = `id, user_id, type, name, config, created_at, updated_at`
+
+func scanChannel(row rowScanner) (*models.Channel, error) {
+	var channel models.Channel
+	err := row.Scan(
+		&channel.ID, &channel.UserID, &channel.Type, &channel.Name,
+		&channel.Config, &channel.CreatedAt, &channel.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+func (d *Database) GetChannel(id string) (*models.Channel, error) {
+	query := `SELECT ` + channelColumns + `
+		FROM channels
+		WHERE id = $1
+	`
+
+	channel, err := scanChannel(d.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error querying channel: %w", err)
+	}
+
+	return channel, nil
+}
+
+func (d *Database) ListChannelsByUser(userID string) ([]*models.Channel, error) {
+	query := `SELECT ` + channelColumns + `
+		FROM channels
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+	rows, err := d.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying channels: %w", err)
+	}
+	defer rows.Close()
+
+	var channels []*models.Channel
+	for rows.Next() {
+		channel, err := scanChannel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning channel row: %w", err)
+		}
+		channels = append(channels, channel)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating channel rows: %w", err)
+	}
+
+	return channels, nil
+}
+
+func (d *Database) CreateChannel(channel *models.Channel) error {
+	if channel.ID == "" {
+		channel.ID = uuid.New().String()
+	}
+
+	now := time.Now().UTC()
+	channel.CreatedAt = now
+	channel.UpdatedAt = now
+
+	query := `INSERT INTO channels (` + channelColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := d.db.Exec(query,
+		channel.ID, channel.UserID, channel.Type, channel.Name,
+		[]byte(channel.Config), channel.CreatedAt, channel.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating channel: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) UpdateChannel(channel *models.Channel) error {
+	channel.UpdatedAt = time.Now().UTC()
+
+	query := `
+		UPDATE channels
+		SET name = $1, config = $2, updated_at = $3
+		WHERE id = $4 AND user_id = $5
+	`
+	result, err := d.db.Exec(query,
+		channel.Name, []byte(channel.Config), channel.UpdatedAt, channel.ID, channel.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating channel: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("channel not found or not owned by user")
+	}
+
+	return nil
+}
+
+func (d *Database) DeleteChannel(id string) error {
+	query := `DELETE FROM channels WHERE id = $1`
+	result, err := d.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("error deleting channel: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("channel not found")
+	}
+
+	return nil
+}
+
+// createNotification queues one notification per channel of the user, or
+// hands the alert to the escalation policy of the job. Users without channels
+// are notified by email at their account address.
+func createNotification(tx *sql.Tx, userID, jobID, eventID string, previousStatus models.JobStatus, now time.Time) error {
+	if escalated, err := escalate(tx, userID, jobID, eventID, previousStatus, now); err != nil || escalated {
+		return err
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO notifications (id, user_id, job_id, event_id, channel_id, previous_status, type, status, created_at)
+		SELECT gen_random_uuid(), $1, $2, $3, c.id, $4, c.type, 'pending', $5
+		FROM channels c
+		WHERE c.user_id = $1
+	`, userID, jobID, eventID, previousStatus, now)
+
+	if err != nil {
+		return fmt.Errorf("error creating notifications: %w", err)
+	}
+
+	if rows, err := result.RowsAffected(); err != nil || rows > 0 {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO notifications (id, user_id, job_id, event_id, previous_status, type, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, uuid.New().String(), userID, jobID, eventID, previousStatus, models.ChannelEmail, "pending", now)
+
+	if err != nil {
+		return fmt.Errorf("error creating notification: %w", err)
+	}
+
+	return nil
+}
+
+// createRecoveryNotifications queues a notification for each channel that
+// was alerted since the incident started, including the default email.
+func createRecoveryNotifications(tx *sql.Tx, userID, jobID, eventID string, previousStatus models.JobStatus, downtime time.Duration, since, now time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO notifications (id, user_id, job_id, event_id, channel_id, previous_status, type, status, downtime, created_at)
+		SELECT gen_random_uuid(), $1, $2, $3, alerted.channel_id, $4, alerted.type, 'pending', $5, $6
+		FROM (
+			SELECT DISTINCT channel_id, type
+			FROM notifications
+			WHERE job_id = $2 AND created_at >= $7
+		) alerted
+	`, userID, jobID, eventID, previousStatus, int64(downtime.Seconds()), now, since)
+
+	if err != nil {
+		return fmt.Errorf("error creating recovery notifications: %w", err)
+	}
+
+	return nil
+}
@@ -2,18 +2,45 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"strconv"
 	"time"
 
-	"github.com/adhocore/gronx"
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/zigamedved/cronsentry/internal/models"
+	"github.com/zigamedved/cronsentry/internal/schedule"
 )
 
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrSlugTaken is returned when another job of the user has the same slug.
+var ErrSlugTaken = errors.New("slug already used by another job")
+
+// ErrJobLimit is returned when creating a job would exceed the jobs a user
+// may have.
+var ErrJobLimit = errors.New("job limit reached")
+
+// slugAttempts is how often a generated slug is retried when a concurrently
+// created job takes it.
+const slugAttempts = 5
+
+// jobChangedChannel is the Postgres notification channel that tells the job
+// checker, on whichever replica leads, about changed jobs.
+const jobChangedChannel = "cronsentry_job_changed"
+
+// NotificationQueuedChannel is the Postgres notification channel that a
+// trigger notifies when a notification is ready to be sent.
+const NotificationQueuedChannel = "cronsentry_notification_queued"
+
 type Database struct {
-	db *sql.DB
+	db      *sql.DB
+	connStr string // for connections outside the pool, e.g. to listen
+	maxJobs int    // per user, 0 for no limit
 }
 
 func NewDatabase() (*Database, error) {
@@ -24,6 +51,11 @@ func NewDatabase() (*Database, error) {
 	dbname := getEnv("DB_NAME", "cronsentry")
 	sslmode := getEnv("DB_SSLMODE", "disable")
 
+	maxJobs, err := strconv.Atoi(getEnv("MAX_JOBS_PER_USER", "500"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_JOBS_PER_USER: %w", err)
+	}
+
 	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		host, port, user, password, dbname, sslmode)
 
@@ -41,28 +73,71 @@ func NewDatabase() (*Database, error) {
 	db.SetMaxIdleConns(5)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
-	return &Database{db: db}, nil
+	return &Database{db: db, connStr: connStr, maxJobs: maxJobs}, nil
+}
+
+// NewDatabaseFromDB wraps a connection pool opened elsewhere, e.g. by tests.
+// Without a connection string Listen is not available, and jobs are not
+// limited.
+func NewDatabaseFromDB(db *sql.DB) *Database {
+	return &Database{db: db}
+}
+
+// notifyJobChanged tells the job checker to reschedule the job after its
+// pings, schedule or status changed. Should the notification fail, the
+// change is picked up by the next resync of the checker.
+func (d *Database) notifyJobChanged(jobID string) {
+	d.db.Exec(`SELECT pg_notify($1, $2)`, jobChangedChannel, jobID)
+}
+
+// Listen opens a connection outside the pool that receives the notifications
+// sent to the channel. The listener reconnects by itself, and sends nil on
+// Notify after reconnecting as notifications may have been missed meanwhile.
+func (d *Database) Listen(channel string, logger *log.Logger) *pq.Listener {
+	listener := pq.NewListener(d.connStr, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Printf("Error listening on %s: %v", channel, err)
+		}
+	})
+
+	if err := listener.Listen(channel); err != nil {
+		logger.Printf("Error listening on %s: %v", channel, err)
+	}
+
+	return listener
 }
 
 func (d *Database) Close() error {
 	return d.db.Close()
 }
 
+const jobColumns = `id, name, slug, description, kind, schedule, period, timezone, grace_period, max_runtime,
+		escalation_policy_id, reminder_interval, max_reminders, last_ping, next_expect, status, user_id, project_id, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(row rowScanner) (*models.Job, error) {
+	var job models.Job
+	err := row.Scan(
+		&job.ID, &job.Name, &job.Slug, &job.Description, &job.Kind, &job.Schedule, &job.Period, &job.Timezone,
+		&job.GracePeriod, &job.MaxRuntime, &job.EscalationPolicyID, &job.ReminderInterval, &job.MaxReminders,
+		&job.LastPing, &job.NextExpect, &job.Status, &job.UserID, &job.ProjectID, &job.CreatedAt, &job.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
 func (d *Database) GetJob(id string) (*models.Job, error) {
-	query := `
-		SELECT id, name, description, schedule, grace_time, 
-		       last_ping, next_expect, status, user_id, created_at, updated_at
+	query := `SELECT ` + jobColumns + `
 		FROM jobs
 		WHERE id = $1
 	`
 
-	var job models.Job
-	err := d.db.QueryRow(query, id).Scan(
-		&job.ID, &job.Name, &job.Description, &job.Schedule,
-		&job.GraceTime, &job.LastPing, &job.NextExpect,
-		&job.Status, &job.UserID, &job.CreatedAt, &job.UpdatedAt,
-	)
-
+	job, err := scanJob(d.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -70,13 +145,11 @@ func (d *Database) GetJob(id string) (*models.Job, error) {
 		return nil, fmt.Errorf("error querying job: %w", err)
 	}
 
-	return &job, nil
+	return job, nil
 }
 
 func (d *Database) ListJobsByUser(userID string) ([]*models.Job, error) {
-	query := `
-		SELECT id, name, description, schedule, grace_time, 
-		       last_ping, next_expect, status, user_id, created_at, updated_at
+	query := `SELECT ` + jobColumns + `
 		FROM jobs
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -89,16 +162,11 @@ func (d *Database) ListJobsByUser(userID string) ([]*models.Job, error) {
 
 	var jobs []*models.Job
 	for rows.Next() {
-		var job models.Job
-		err := rows.Scan(
-			&job.ID, &job.Name, &job.Description, &job.Schedule,
-			&job.GraceTime, &job.LastPing, &job.NextExpect,
-			&job.Status, &job.UserID, &job.CreatedAt, &job.UpdatedAt,
-		)
+		job, err := scanJob(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning job row: %w", err)
 		}
-		jobs = append(jobs, &job)
+		jobs = append(jobs, job)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -108,6 +176,9 @@ func (d *Database) ListJobsByUser(userID string) ([]*models.Job, error) {
 	return jobs, nil
 }
 
+// CreateJob creates the job, with a slug generated from its name when it has
+// none. It returns ErrJobLimit when the user already has as many jobs as
+// allowed.
 func (d *Database) CreateJob(job *models.Job) error {
 	if job.ID == "" {
 		job.ID = uuid.New().String()
@@ -117,39 +188,104 @@ func (d *Database) CreateJob(job *models.Job) error {
 	job.CreatedAt = now
 	job.UpdatedAt = now
 
-	query := `
-		INSERT INTO jobs (id, name, description, schedule, grace_time, 
-		                 last_ping, next_expect, status, user_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	generated := job.Slug == ""
+	for attempt := 1; ; attempt++ {
+		if generated {
+			slug, err := d.uniqueSlug(job.UserID, models.Slugify(job.Name))
+			if err != nil {
+				return err
+			}
+			job.Slug = slug
+		}
+
+		err := d.insertJob(job)
+		if errors.Is(err, ErrSlugTaken) && generated && attempt < slugAttempts {
+			// taken by a concurrently created job, the next suffix is free
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		break
+	}
+
+	d.notifyJobChanged(job.ID)
+
+	return nil
+}
+
+func (d *Database) insertJob(job *models.Job) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// locking the user serializes job creation, so the limit holds
+	if _, err := tx.Exec(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, job.UserID); err != nil {
+		return fmt.Errorf("error locking user: %w", err)
+	}
+
+	if d.maxJobs > 0 {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM jobs WHERE user_id = $1`, job.UserID).Scan(&count); err != nil {
+			return fmt.Errorf("error counting jobs: %w", err)
+		}
+		if count >= d.maxJobs {
+			return ErrJobLimit
+		}
+	}
+
+	query := `INSERT INTO jobs (` + jobColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
 	`
-	_, err := d.db.Exec(query,
-		job.ID, job.Name, job.Description, job.Schedule,
-		job.GraceTime, job.LastPing, job.NextExpect,
-		job.Status, job.UserID, job.CreatedAt, job.UpdatedAt,
+	_, err = tx.Exec(query,
+		job.ID, job.Name, job.Slug, job.Description, job.Kind, job.Schedule, job.Period, job.Timezone,
+		job.GracePeriod, job.MaxRuntime, job.EscalationPolicyID, job.ReminderInterval, job.MaxReminders,
+		job.LastPing, job.NextExpect, job.Status, job.UserID, job.ProjectID, job.CreatedAt, job.UpdatedAt,
 	)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrSlugTaken
+		}
 		return fmt.Errorf("error creating job: %w", err)
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
 	return nil
 }
 
+// UpdateJob saves the job. Pausing it ends the escalation of its alert, so
+// no further steps are notified while it is paused.
 func (d *Database) UpdateJob(job *models.Job) error {
 	job.UpdatedAt = time.Now().UTC()
 
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `
 		UPDATE jobs
-		SET name = $1, description = $2, schedule = $3,
-		grace_time = $4, last_ping = $5, next_expect = $6, 
-		status = $7, updated_at = $8
-		WHERE id = $9 AND user_id = $10
+		SET name = $1, slug = $2, description = $3, kind = $4, schedule = $5, period = $6,
+		timezone = $7, grace_period = $8, max_runtime = $9, escalation_policy_id = $10,
+		reminder_interval = $11, max_reminders = $12, last_ping = $13, next_expect = $14,
+		status = $15, project_id = $16, updated_at = $17
+		WHERE id = $18 AND user_id = $19
 	`
-	result, err := d.db.Exec(query,
-		job.Name, job.Description, job.Schedule,
-		job.GraceTime, job.LastPing, job.NextExpect, job.Status,
-		job.UpdatedAt, job.ID, job.UserID,
+	result, err := tx.Exec(query,
+		job.Name, job.Slug, job.Description, job.Kind, job.Schedule, job.Period, job.Timezone,
+		job.GracePeriod, job.MaxRuntime, job.EscalationPolicyID, job.ReminderInterval, job.MaxReminders,
+		job.LastPing, job.NextExpect, job.Status, job.ProjectID, job.UpdatedAt, job.ID, job.UserID,
 	)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrSlugTaken
+		}
 		return fmt.Errorf("error updating job: %w", err)
 	}
 
@@ -162,17 +298,42 @@ func (d *Database) UpdateJob(job *models.Job) error {
 		return fmt.Errorf("job not found or not owned by user")
 	}
 
+	if job.Status == models.StatusPaused {
+		if err := endEscalation(tx, job.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	d.notifyJobChanged(job.ID)
+
 	return nil
 }
 
-func (d *Database) RecordPing(jobID string) error {
+func (d *Database) RecordPing(jobID string, ping models.Ping) error {
 	now := time.Now().UTC()
 
+	data, err := json.Marshal(ping)
+	if err != nil {
+		return fmt.Errorf("error encoding ping data: %w", err)
+	}
+
 	job, err := d.GetJob(jobID)
 	if err != nil {
 		return fmt.Errorf("error recording ping: %w", err)
 	}
-	nextTick, err := gronx.NextTickAfter(job.Schedule, now, true)
+	if job == nil {
+		return ErrJobNotFound
+	}
+
+	if ping.Kind == models.PingStart {
+		return d.recordStart(jobID, string(data), now)
+	}
+
+	nextTick, err := schedule.NextExpect(job, now)
 	if err != nil {
 		return fmt.Errorf("error calculating next tick: %w", err)
 	}
@@ -194,22 +355,33 @@ func (d *Database) RecordPing(jobID string) error {
 	if err != nil {
 		tx.Rollback()
 		if err == sql.ErrNoRows {
-			return fmt.Errorf("job not found")
+			return ErrJobNotFound
 		}
 		return fmt.Errorf("error updating job ping: %w", err)
 	}
 
-	eventID := uuid.New().String()
-	eventType := "ping"
+	eventType := models.TypePing
+	runStatus := models.RunSuccess
+	newStatus := currentStatus
 
-	if currentStatus == models.StatusMissing {
-		eventType = "recovery"
+	if ping.Kind == models.PingFail {
+		eventType = models.TypeFail
+		runStatus = models.RunFailed
+		if currentStatus != models.StatusPaused {
+			newStatus = models.StatusFailed
+		}
+	} else if currentStatus == models.StatusMissing || currentStatus == models.StatusFailed ||
+		currentStatus == models.StatusLongRunning {
+		eventType = models.TypeRecovery
+		newStatus = models.StatusHealthy
+	}
 
+	if newStatus != currentStatus {
 		_, err = tx.Exec(`
 			UPDATE jobs
 			SET status = $1
 			WHERE id = $2
-		`, models.StatusHealthy, jobID)
+		`, newStatus, jobID)
 
 		if err != nil {
 			tx.Rollback()
@@ -217,14 +389,47 @@ func (d *Database) RecordPing(jobID string) error {
 		}
 	}
 
-	_, err = tx.Exec(`
-		INSERT INTO job_events (id, job_id, type, data, created_at)
-		VALUES ($1, $2, $3, $4, $5)
-	`, eventID, jobID, eventType, "{}", now)
+	// the incident has to be looked up before the recovery event closes it
+	var downSince time.Time
+	if eventType == models.TypeRecovery {
+		downSince, err = incidentStart(tx, jobID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
 
+	eventID, err := createEvent(tx, jobID, eventType, string(data), now)
 	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("error creating event record: %w", err)
+		return err
+	}
+
+	if err := finishRun(tx, jobID, runStatus, now); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if newStatus == models.StatusFailed && currentStatus != models.StatusFailed {
+		if err := createNotification(tx, job.UserID, jobID, eventID, currentStatus, now); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if eventType == models.TypeRecovery {
+		if err := endEscalation(tx, jobID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if eventType == models.TypeRecovery && !downSince.IsZero() {
+		downtime := now.Sub(downSince).Round(time.Second)
+		if err := createRecoveryNotifications(tx, job.UserID, jobID, eventID, currentStatus, downtime, downSince, now); err != nil {
+			tx.Rollback()
+			return err
+		}
 	}
 
 	err = tx.Commit()
@@ -232,9 +437,25 @@ func (d *Database) RecordPing(jobID string) error {
 		return fmt.Errorf("error committing transaction: %w", err)
 	}
 
+	d.notifyJobChanged(jobID)
+
 	return nil
 }
 
+func createEvent(tx *sql.Tx, jobID string, eventType models.JobEventType, data string, now time.Time) (string, error) {
+	eventID := uuid.New().String()
+	_, err := tx.Exec(`
+		INSERT INTO job_events (id, job_id, type, data, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, eventID, jobID, eventType, data, now)
+
+	if err != nil {
+		return "", fmt.Errorf("error creating event record: %w", err)
+	}
+
+	return eventID, nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -246,6 +467,8 @@ func (d *Database) GetDB() *sql.DB {
 	return d.db
 }
 
+// DeleteJob deletes the job, its escalation, runs, events and notifications
+// are deleted along with it.
 func (d *Database) DeleteJob(id string) error {
 	query := `DELETE FROM jobs WHERE id = $1`
 	result, err := d.db.Exec(query, id)
@@ -262,5 +485,7 @@ func (d *Database) DeleteJob(id string) error {
 		return fmt.Errorf("job not found")
 	}
 
+	d.notifyJobChanged(id)
+
 	return nil
 }
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+// newMockDatabase returns a Database on top of sqlmock, the expectations are
+// checked when the test ends.
+func newMockDatabase(t *testing.T) (*Database, sqlmock.Sqlmock) {
+	t.Helper()
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		sqlDB.Close()
+	})
+
+	return &Database{db: sqlDB}, mock
+}
+
+func TestCreateJobRetriesGeneratedSlug(t *testing.T) {
+	database, mock := newMockDatabase(t)
+
+	mock.ExpectQuery(`SELECT slug`).WithArgs("user-1", "backup", "backup-%").
+		WillReturnRows(sqlmock.NewRows([]string{"slug"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(`FOR UPDATE`).WithArgs("user-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COUNT`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec(`INSERT INTO jobs`).WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectRollback()
+	mock.ExpectQuery(`SELECT slug`).WithArgs("user-1", "backup", "backup-%").
+		WillReturnRows(sqlmock.NewRows([]string{"slug"}).AddRow("backup"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`FOR UPDATE`).WithArgs("user-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COUNT`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectExec(`INSERT INTO jobs`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`pg_notify`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	database.maxJobs = 10
+	job := &models.Job{Name: "Backup", UserID: "user-1"}
+	if err := database.CreateJob(job); err != nil {
+		t.Fatalf("CreateJob() error = %v", err)
+	}
+	if job.Slug != "backup-2" {
+		t.Errorf("CreateJob() slug = %q, want backup-2", job.Slug)
+	}
+}
+
+func TestCreateJobLimit(t *testing.T) {
+	database, mock := newMockDatabase(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`FOR UPDATE`).WithArgs("user-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COUNT`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+	mock.ExpectRollback()
+
+	database.maxJobs = 10
+	err := database.CreateJob(&models.Job{Name: "Backup", Slug: "backup", UserID: "user-1"})
+	if !errors.Is(err, ErrJobLimit) {
+		t.Fatalf("CreateJob() error = %v, want ErrJobLimit", err)
+	}
+}
+
+func TestUpdateJobPausedEndsEscalation(t *testing.T) {
+	database, mock := newMockDatabase(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE jobs`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM escalations`).WithArgs("job-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`pg_notify`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := database.UpdateJob(&models.Job{ID: "job-1", UserID: "user-1", Status: models.StatusPaused})
+	if err != nil {
+		t.Fatalf("UpdateJob() error = %v", err)
+	}
+}
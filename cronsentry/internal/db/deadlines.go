@@ -0,0 +1,80 @@
+package db
+
+import (
+	"container/heap"
+	"time"
+)
+
+type deadline struct {
+	jobID string
+	at    time.Time
+	index int
+}
+
+// deadlineQueue is a min-heap of job deadlines with at most one entry per job.
+type deadlineQueue struct {
+	items []*deadline
+	byJob map[string]*deadline
+}
+
+func newDeadlineQueue() *deadlineQueue {
+	return &deadlineQueue{byJob: make(map[string]*deadline)}
+}
+
+func (q *deadlineQueue) Len() int           { return len(q.items) }
+func (q *deadlineQueue) Less(i, j int) bool { return q.items[i].at.Before(q.items[j].at) }
+
+func (q *deadlineQueue) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+	q.items[i].index = i
+	q.items[j].index = j
+}
+
+func (q *deadlineQueue) Push(x any) {
+	d := x.(*deadline)
+	d.index = len(q.items)
+	q.items = append(q.items, d)
+	q.byJob[d.jobID] = d
+}
+
+func (q *deadlineQueue) Pop() any {
+	n := len(q.items)
+	d := q.items[n-1]
+	q.items[n-1] = nil
+	q.items = q.items[:n-1]
+	delete(q.byJob, d.jobID)
+	return d
+}
+
+// set adds or moves the deadline of a job.
+func (q *deadlineQueue) set(jobID string, at time.Time) {
+	if d, ok := q.byJob[jobID]; ok {
+		d.at = at
+		heap.Fix(q, d.index)
+		return
+	}
+	heap.Push(q, &deadline{jobID: jobID, at: at})
+}
+
+func (q *deadlineQueue) remove(jobID string) {
+	if d, ok := q.byJob[jobID]; ok {
+		heap.Remove(q, d.index)
+	}
+}
+
+// popDue removes and returns the jobs whose deadline is not after now.
+func (q *deadlineQueue) popDue(now time.Time) []string {
+	var due []string
+	for q.Len() > 0 && !q.items[0].at.After(now) {
+		due = append(due, heap.Pop(q).(*deadline).jobID)
+	}
+	return due
+}
+
+// next returns the earliest deadline, if any.
+func (q *deadlineQueue) next() (time.Time, bool) {
+	if q.Len() == 0 {
+		return time.Time{}, false
+	}
+	return q.items[0].at, true
+}
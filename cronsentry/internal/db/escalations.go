@@ -0,0 +1,332 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+const policyColumns = `id, user_id, name, steps, created_at, updated_at`
+
+func scanPolicy(row rowScanner) (*models.EscalationPolicy, error) {
+	var policy models.EscalationPolicy
+	var steps []byte
+	err := row.Scan(&policy.ID, &policy.UserID, &policy.Name, &steps, &policy.CreatedAt, &policy.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(steps, &policy.Steps); err != nil {
+		return nil, fmt.Errorf("error decoding policy steps: %w", err)
+	}
+	return &policy, nil
+}
+
+func (d *Database) GetEscalationPolicy(id string) (*models.EscalationPolicy, error) {
+	query := `SELECT ` + policyColumns + `
+		FROM escalation_policies
+		WHERE id = $1
+	`
+
+	policy, err := scanPolicy(d.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error querying escalation policy: %w", err)
+	}
+
+	return policy, nil
+}
+
+func (d *Database) ListEscalationPoliciesByUser(userID string) ([]*models.EscalationPolicy, error) {
+	query := `SELECT ` + policyColumns + `
+		FROM escalation_policies
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+	rows, err := d.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying escalation policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []*models.EscalationPolicy
+	for rows.Next() {
+		policy, err := scanPolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning escalation policy row: %w", err)
+		}
+		policies = append(policies, policy)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating escalation policy rows: %w", err)
+	}
+
+	return policies, nil
+}
+
+func (d *Database) CreateEscalationPolicy(policy *models.EscalationPolicy) error {
+	if policy.ID == "" {
+		policy.ID = uuid.New().String()
+	}
+
+	now := time.Now().UTC()
+	policy.CreatedAt = now
+	policy.UpdatedAt = now
+
+	steps, err := json.Marshal(policy.Steps)
+	if err != nil {
+		return fmt.Errorf("error encoding policy steps: %w", err)
+	}
+
+	query := `INSERT INTO escalation_policies (` + policyColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err = d.db.Exec(query, policy.ID, policy.UserID, policy.Name, steps, policy.CreatedAt, policy.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating escalation policy: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateEscalationPolicy changes the policy. Escalations already in progress
+// continue with the new steps.
+func (d *Database) UpdateEscalationPolicy(policy *models.EscalationPolicy) error {
+	policy.UpdatedAt = time.Now().UTC()
+
+	steps, err := json.Marshal(policy.Steps)
+	if err != nil {
+		return fmt.Errorf("error encoding policy steps: %w", err)
+	}
+
+	query := `
+		UPDATE escalation_policies
+		SET name = $1, steps = $2, updated_at = $3
+		WHERE id = $4 AND user_id = $5
+	`
+	result, err := d.db.Exec(query, policy.Name, steps, policy.UpdatedAt, policy.ID, policy.UserID)
+	if err != nil {
+		return fmt.Errorf("error updating escalation policy: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("escalation policy not found or not owned by user")
+	}
+
+	return nil
+}
+
+func (d *Database) DeleteEscalationPolicy(id string) error {
+	query := `DELETE FROM escalation_policies WHERE id = $1`
+	result, err := d.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("error deleting escalation policy: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("escalation policy not found")
+	}
+
+	return nil
+}
+
+// AckJob acknowledges the current alert of a job, which stops its
+// escalation. It returns nil when there is no unacknowledged escalation.
+func (d *Database) AckJob(jobID, userID string) (*models.Escalation, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	var escalation models.Escalation
+	err = tx.QueryRow(`
+		UPDATE escalations
+		SET acked_at = $1, acked_by = $2, next_at = NULL
+		WHERE job_id = $3 AND acked_at IS NULL
+		RETURNING job_id, policy_id, step, next_at, started_at, acked_at, acked_by
+	`, now, userID, jobID).Scan(
+		&escalation.JobID, &escalation.PolicyID, &escalation.Step, &escalation.NextAt,
+		&escalation.StartedAt, &escalation.AckedAt, &escalation.AckedBy,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error acknowledging alert: %w", err)
+	}
+
+	data, err := json.Marshal(map[string]string{"user_id": userID})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding event data: %w", err)
+	}
+
+	if _, err := createEvent(tx, jobID, models.TypeAck, string(data), now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	d.notifyJobChanged(jobID)
+
+	return &escalation, nil
+}
+
+// escalate starts the escalation policy of a job for a new alert, and reports
+// false when the job has no policy. While the job is already escalated, the
+// alert goes to the channels of the steps notified so far instead.
+func escalate(tx *sql.Tx, userID, jobID, eventID string, previousStatus models.JobStatus, now time.Time) (bool, error) {
+	var policyID sql.NullString
+	var stepsData []byte
+	err := tx.QueryRow(`
+		SELECT p.id, p.steps
+		FROM jobs j
+		LEFT JOIN escalation_policies p ON j.escalation_policy_id = p.id
+		WHERE j.id = $1
+	`, jobID).Scan(&policyID, &stepsData)
+	if err != nil {
+		return false, fmt.Errorf("error querying escalation policy: %w", err)
+	}
+	if !policyID.Valid {
+		return false, nil
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO escalations (job_id, policy_id, user_id, event_id, previous_status, step, next_at, started_at)
+		VALUES ($1, $2, $3, $4, $5, 0, $6, $6)
+		ON CONFLICT (job_id) DO NOTHING
+	`, jobID, policyID.String, userID, eventID, previousStatus, now)
+	if err != nil {
+		return false, fmt.Errorf("error starting escalation: %w", err)
+	}
+
+	if rows, err := result.RowsAffected(); err != nil {
+		return false, fmt.Errorf("error getting rows affected: %w", err)
+	} else if rows > 0 {
+		// steps without a delay are notified right away
+		return true, advanceEscalation(tx, jobID, now)
+	}
+
+	var steps []models.EscalationStep
+	if err := json.Unmarshal(stepsData, &steps); err != nil {
+		return false, fmt.Errorf("error decoding policy steps: %w", err)
+	}
+
+	var step int
+	err = tx.QueryRow(`SELECT step FROM escalations WHERE job_id = $1 FOR UPDATE`, jobID).Scan(&step)
+	if err != nil {
+		return false, fmt.Errorf("error querying escalation: %w", err)
+	}
+
+	var channelIDs []string
+	for _, s := range steps[:min(step, len(steps))] {
+		channelIDs = append(channelIDs, s.ChannelIDs...)
+	}
+
+	return true, notifyChannels(tx, userID, jobID, eventID, previousStatus, channelIDs, now)
+}
+
+// advanceEscalation notifies the channels of every step of the job's
+// escalation that is due, and schedules the next step.
+func advanceEscalation(tx *sql.Tx, jobID string, now time.Time) error {
+	var userID string
+	var eventID sql.NullString
+	var previousStatus models.JobStatus
+	var step int
+	var startedAt time.Time
+	var stepsData []byte
+	err := tx.QueryRow(`
+		SELECT e.user_id, e.event_id, COALESCE(e.previous_status, ''), e.step, e.started_at, p.steps
+		FROM escalations e
+		JOIN escalation_policies p ON e.policy_id = p.id
+		WHERE e.job_id = $1 AND e.acked_at IS NULL AND e.next_at <= $2
+		FOR UPDATE OF e
+	`, jobID, now).Scan(&userID, &eventID, &previousStatus, &step, &startedAt, &stepsData)
+	if err != nil {
+		// acknowledged, recovered or advanced in the meantime
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("error querying escalation: %w", err)
+	}
+
+	var steps []models.EscalationStep
+	if err := json.Unmarshal(stepsData, &steps); err != nil {
+		return fmt.Errorf("error decoding policy steps: %w", err)
+	}
+
+	for ; step < len(steps) && !startedAt.Add(steps[step].Delay.Duration()).After(now); step++ {
+		err := notifyChannels(tx, userID, jobID, eventID.String, previousStatus, steps[step].ChannelIDs, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	var nextAt *time.Time
+	if step < len(steps) {
+		at := startedAt.Add(steps[step].Delay.Duration())
+		nextAt = &at
+	}
+
+	_, err = tx.Exec(`
+		UPDATE escalations
+		SET step = $1, next_at = $2
+		WHERE job_id = $3
+	`, step, nextAt, jobID)
+	if err != nil {
+		return fmt.Errorf("error updating escalation: %w", err)
+	}
+
+	return nil
+}
+
+// endEscalation removes the escalation of a job once it recovered or was
+// paused.
+func endEscalation(tx *sql.Tx, jobID string) error {
+	if _, err := tx.Exec(`DELETE FROM escalations WHERE job_id = $1`, jobID); err != nil {
+		return fmt.Errorf("error ending escalation: %w", err)
+	}
+	return nil
+}
+
+// notifyChannels queues a notification for each of the given channels of the
+// user.
+func notifyChannels(tx *sql.Tx, userID, jobID, eventID string, previousStatus models.JobStatus, channelIDs []string, now time.Time) error {
+	if len(channelIDs) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO notifications (id, user_id, job_id, event_id, channel_id, previous_status, type, status, created_at)
+		SELECT gen_random_uuid(), $1, $2, NULLIF($3, ''), c.id, $4, c.type, 'pending', $5
+		FROM channels c
+		WHERE c.user_id = $1 AND c.id = ANY($6)
+	`, userID, jobID, eventID, previousStatus, now, pq.Array(channelIDs))
+
+	if err != nil {
+		return fmt.Errorf("error creating notifications: %w", err)
+	}
+
+	return nil
+}
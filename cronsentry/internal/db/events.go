@@ -0,0 +1,161 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+func (d *Database) GetEvent(jobID, eventID string) (*models.JobEvent, error) {
+	query := `
+		SELECT id, job_id, type, data, created_at
+		FROM job_events
+		WHERE id = $1 AND job_id = $2
+	`
+
+	var event models.JobEvent
+	err := d.db.QueryRow(query, eventID, jobID).Scan(
+		&event.ID, &event.JobID, &event.Type, &event.Data, &event.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error querying event: %w", err)
+	}
+
+	return &event, nil
+}
+
+// incidentStart returns when the current incident of a job began, that is the
+// time of the first alert event since the last recovery.
+func incidentStart(tx *sql.Tx, jobID string) (time.Time, error) {
+	query := `
+		SELECT MIN(created_at)
+		FROM job_events
+		WHERE job_id = $1 AND type = ANY($2) AND created_at > COALESCE((
+			SELECT MAX(created_at) FROM job_events WHERE job_id = $1 AND type = $3
+		), '-infinity')
+	`
+
+	alertTypes := []string{string(models.TypeMiss), string(models.TypeFail), string(models.TypeLongRunning)}
+
+	var start sql.NullTime
+	err := tx.QueryRow(query, jobID, pq.Array(alertTypes), models.TypeRecovery).Scan(&start)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error querying incident start: %w", err)
+	}
+
+	return start.Time, nil
+}
+
+// ListEvents returns one page of events, newest first, and the cursor of the
+// next page. The cursor is empty when there are no more events.
+func (d *Database) ListEvents(filter models.EventFilter) ([]*models.JobEvent, string, error) {
+	conditions := []string{"j.user_id = $1"}
+	args := []any{filter.UserID}
+
+	addCondition := func(format string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.JobID != "" {
+		addCondition("e.job_id = $%d", filter.JobID)
+	}
+	if len(filter.Types) > 0 {
+		types := make([]string, len(filter.Types))
+		for i, t := range filter.Types {
+			types[i] = string(t)
+		}
+		addCondition("e.type = ANY($%d)", pq.Array(types))
+	}
+	if filter.Since != nil {
+		addCondition("e.created_at >= $%d", *filter.Since)
+	}
+	if filter.Until != nil {
+		addCondition("e.created_at < $%d", *filter.Until)
+	}
+	if filter.Cursor != "" {
+		createdAt, id, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		args = append(args, createdAt, id)
+		conditions = append(conditions, fmt.Sprintf("(e.created_at, e.id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	// fetch one extra row to know whether another page exists
+	args = append(args, filter.Limit+1)
+	query := fmt.Sprintf(`
+		SELECT e.id, e.job_id, e.type, e.data, e.created_at
+		FROM job_events e
+		JOIN jobs j ON e.job_id = j.id
+		WHERE %s
+		ORDER BY e.created_at DESC, e.id DESC
+		LIMIT $%d
+	`, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("error querying events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*models.JobEvent
+	for rows.Next() {
+		var event models.JobEvent
+		err := rows.Scan(&event.ID, &event.JobID, &event.Type, &event.Data, &event.CreatedAt)
+		if err != nil {
+			return nil, "", fmt.Errorf("error scanning event row: %w", err)
+		}
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error iterating event rows: %w", err)
+	}
+
+	var nextCursor string
+	if len(events) > filter.Limit {
+		events = events[:filter.Limit]
+		last := events[len(events)-1]
+		nextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	return events, nextCursor, nil
+}
+
+func encodeCursor(createdAt time.Time, id string) string {
+	raw := strconv.FormatInt(createdAt.UnixNano(), 10) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	return time.Unix(0, n).UTC(), id, nil
+}
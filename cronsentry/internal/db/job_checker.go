@@ -1,17 +1,43 @@
 package db
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"log"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/zigamedved/cronsentry/internal/models"
 )
 
+const (
+	// resyncInterval is how often the deadline queue is rebuilt from the
+	// database, in case a change notification got lost.
+	resyncInterval = 5 * time.Minute
+	// retryDelay postpones deadlines that are still due after being checked,
+	// e.g. because marking the job failed. Deadlines that are overdue for any
+	// other reason, such as after taking over leadership, are due right away.
+	retryDelay = 5 * time.Second
+	// leaderInterval is how often a standby replica tries to become the
+	// leader, and how often the leader verifies it still holds the lock.
+	leaderInterval = 10 * time.Second
+	// checkerLockKey identifies the advisory lock of the active job checker.
+	checkerLockKey = 0x63726f6e
+)
+
+// JobChecker keeps an in-memory queue of job deadlines (next_expect plus the
+// grace period, the max runtime of an open run, the next escalation step or
+// reminder) and checks each job when its deadline passes. Pings and job
+// updates reschedule the affected job.
+//
+// When several replicas run, only the one holding the leader lock checks jobs.
+// Replicas announce changed jobs with a Postgres notification, so the leader
+// learns about changes made through any of them.
 type JobChecker struct {
 	db     *Database
 	logger *log.Logger
+	leader *Leader
+	queue  *deadlineQueue
 	done   chan struct{}
 }
 
@@ -19,21 +45,31 @@ func NewJobChecker(database *Database, logger *log.Logger) *JobChecker {
 	return &JobChecker{
 		db:     database,
 		logger: logger,
+		leader: NewLeader(database, checkerLockKey),
+		queue:  newDeadlineQueue(),
 		done:   make(chan struct{}),
 	}
 }
 
 func (jc *JobChecker) Start() {
 	go func() {
-		ticker := time.NewTicker(10 * time.Second)
-		defer ticker.Stop()
+		defer jc.leader.Release()
 
 		for {
-			select {
-			case <-ticker.C:
-				if err := jc.checkJobs(); err != nil {
-					jc.logger.Printf("Error checking jobs: %v", err)
+			leader, err := jc.leader.TryAcquire(context.Background())
+			if err != nil {
+				jc.logger.Printf("Error acquiring job checker leadership: %v", err)
+			}
+			if leader {
+				jc.logger.Println("Acquired job checker leadership")
+				if !jc.run() {
+					return
 				}
+				jc.logger.Println("Lost job checker leadership")
+			}
+
+			select {
+			case <-time.After(leaderInterval):
 			case <-jc.done:
 				return
 			}
@@ -41,94 +77,386 @@ func (jc *JobChecker) Start() {
 	}()
 }
 
+// run checks jobs while this replica is the leader. It returns false when the
+// checker was stopped and true when leadership was lost.
+func (jc *JobChecker) run() bool {
+	// listen before loading the deadlines, so no change falls in between
+	listener := jc.db.Listen(jobChangedChannel, jc.logger)
+	defer listener.Close()
+
+	jc.rebuild()
+
+	resync := time.NewTicker(resyncInterval)
+	defer resync.Stop()
+
+	leaderCheck := time.NewTicker(leaderInterval)
+	defer leaderCheck.Stop()
+
+	for {
+		wait := resyncInterval
+		if next, ok := jc.queue.next(); ok {
+			wait = time.Until(next)
+		}
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-timer.C:
+			for _, jobID := range jc.queue.popDue(time.Now()) {
+				if err := jc.checkJobs(jobID); err != nil {
+					jc.logger.Printf("Error checking job %s: %v", jobID, err)
+				}
+				jc.reschedule(jobID, true)
+			}
+		case notification := <-listener.Notify:
+			// nil after reconnecting, changes may have been missed meanwhile
+			if notification == nil {
+				jc.rebuild()
+			} else {
+				jc.reschedule(notification.Extra, false)
+			}
+		case <-resync.C:
+			jc.rebuild()
+		case <-leaderCheck.C:
+			if err := jc.leader.Check(context.Background()); err != nil {
+				jc.logger.Printf("Error checking job checker leadership: %v", err)
+				timer.Stop()
+				return true
+			}
+		case <-jc.done:
+			timer.Stop()
+			return false
+		}
+		timer.Stop()
+	}
+}
+
 func (jc *JobChecker) Stop() {
 	close(jc.done)
 }
 
-func (jc *JobChecker) checkJobs() error {
+// rebuild checks all jobs and reloads every deadline from the database.
+func (jc *JobChecker) rebuild() {
+	if err := jc.checkJobs(""); err != nil {
+		jc.logger.Printf("Error checking jobs: %v", err)
+	}
+
+	deadlines, err := jc.loadDeadlines("")
+	if err != nil {
+		jc.logger.Printf("Error loading job deadlines: %v", err)
+		return
+	}
+
+	jc.queue = newDeadlineQueue()
+	for jobID, at := range deadlines {
+		jc.queue.set(jobID, at)
+	}
+}
+
+// reschedule reloads the deadline of the job. When the job was just checked
+// and its deadline is still due, the check did not handle it and it is tried
+// again after retryDelay.
+func (jc *JobChecker) reschedule(jobID string, checked bool) {
+	deadlines, err := jc.loadDeadlines(jobID)
+	if err != nil {
+		jc.logger.Printf("Error loading deadline of job %s: %v", jobID, err)
+		jc.queue.set(jobID, time.Now().Add(retryDelay))
+		return
+	}
+
+	at, ok := deadlines[jobID]
+	if !ok {
+		jc.queue.remove(jobID)
+		return
+	}
+
+	if now := time.Now(); checked && !at.After(now) {
+		at = now.Add(retryDelay)
+	}
+	jc.queue.set(jobID, at)
+}
+
+// loadDeadlines returns the next deadline of each job that has one, or only
+// of the given job when jobID is not empty.
+func (jc *JobChecker) loadDeadlines(jobID string) (map[string]time.Time, error) {
 	query := `
-		SELECT id, name, user_id, last_ping, next_expect, grace_time
+		SELECT j.id, LEAST(
+			CASE WHEN j.status NOT IN ($1, $2)
+				THEN j.next_expect + make_interval(secs => j.grace_period) END,
+			CASE WHEN j.max_runtime > 0 AND j.status NOT IN ($1, $2, $3) AND r.finished_at IS NULL
+				THEN r.started_at + make_interval(secs => j.max_runtime) END,
+			e.next_at
+		)
+		FROM jobs j
+		LEFT JOIN escalations e ON e.job_id = j.id
+		LEFT JOIN LATERAL (
+			SELECT started_at, finished_at
+			FROM job_runs
+			WHERE job_id = j.id AND started_at IS NOT NULL
+			ORDER BY started_at DESC
+			LIMIT 1
+		) r ON true
+		WHERE $4 = '' OR j.id = $4
+	`
+
+	rows, err := jc.db.db.Query(query, models.StatusPaused, models.StatusMissing, models.StatusLongRunning, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying deadlines: %w", err)
+	}
+	defer rows.Close()
+
+	deadlines := make(map[string]time.Time)
+	for rows.Next() {
+		var id string
+		var at sql.NullTime
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, fmt.Errorf("error scanning deadline: %w", err)
+		}
+		if at.Valid {
+			deadlines[id] = at.Time
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deadlines: %w", err)
+	}
+
+	reminders, err := dueReminders(jc.db.db, jobID)
+	if err != nil {
+		return nil, err
+	}
+	for id, r := range reminders {
+		if at, ok := deadlines[id]; !ok || r.dueAt.Before(at) {
+			deadlines[id] = r.dueAt
+		}
+	}
+
+	return deadlines, nil
+}
+
+// checkJobs marks overdue jobs, escalates unacknowledged alerts and reminds
+// about jobs that stay down, or only the given job when jobID is not empty.
+func (jc *JobChecker) checkJobs(jobID string) error {
+	if err := jc.checkMissingJobs(jobID); err != nil {
+		return err
+	}
+	if err := jc.checkLongRunningJobs(jobID); err != nil {
+		return err
+	}
+	if err := jc.checkEscalations(jobID); err != nil {
+		return err
+	}
+	return jc.checkReminders(jobID)
+}
+
+func (jc *JobChecker) checkMissingJobs(jobID string) error {
+	query := `
+		SELECT id, user_id
 		FROM jobs
 		WHERE status != $1 AND status != $2
-		AND next_expect < $3
+		AND next_expect + make_interval(secs => grace_period) <= $3
+		AND ($4 = '' OR id = $4)
 	`
 
 	now := time.Now().UTC()
-	rows, err := jc.db.db.Query(query, models.StatusPaused, models.StatusMissing, now)
+	rows, err := jc.db.db.Query(query, models.StatusPaused, models.StatusMissing, now, jobID)
 	if err != nil {
 		return fmt.Errorf("error querying jobs: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var job struct {
-			ID         string
-			Name       string
-			UserID     string
-			LastPing   time.Time
-			NextExpect time.Time
-			GraceTime  int
+		var id, userID string
+		if err := rows.Scan(&id, &userID); err != nil {
+			return fmt.Errorf("error scanning job: %w", err)
+		}
+
+		if err := jc.markJob(id, userID, models.StatusMissing, models.TypeMiss); err != nil {
+			jc.logger.Printf("Error marking job as missing: %v", err)
 		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating jobs: %w", err)
+	}
+
+	return nil
+}
 
-		if err := rows.Scan(&job.ID, &job.Name, &job.UserID, &job.LastPing, &job.NextExpect, &job.GraceTime); err != nil {
+// checkLongRunningJobs flags jobs whose latest run was started but has not
+// finished within the max runtime of the job.
+func (jc *JobChecker) checkLongRunningJobs(jobID string) error {
+	query := `
+		SELECT j.id, j.user_id
+		FROM jobs j
+		JOIN LATERAL (
+			SELECT started_at, finished_at
+			FROM job_runs
+			WHERE job_id = j.id AND started_at IS NOT NULL
+			ORDER BY started_at DESC
+			LIMIT 1
+		) r ON true
+		WHERE j.max_runtime > 0
+		AND j.status NOT IN ($1, $2, $3)
+		AND r.finished_at IS NULL
+		AND r.started_at + make_interval(secs => j.max_runtime) <= $4
+		AND ($5 = '' OR j.id = $5)
+	`
+
+	rows, err := jc.db.db.Query(query, models.StatusPaused, models.StatusMissing, models.StatusLongRunning, time.Now().UTC(), jobID)
+	if err != nil {
+		return fmt.Errorf("error querying runs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, userID string
+		if err := rows.Scan(&id, &userID); err != nil {
 			return fmt.Errorf("error scanning job: %w", err)
 		}
 
-		if job.NextExpect.Add(time.Duration(time.Duration(job.GraceTime).Minutes())).Before(now) {
-			if err := jc.markJobMissing(job.ID, job.Name, job.UserID); err != nil {
-				jc.logger.Printf("Error marking job as missing: %v", err)
-			}
+		if err := jc.markJob(id, userID, models.StatusLongRunning, models.TypeLongRunning); err != nil {
+			jc.logger.Printf("Error marking job as long running: %v", err)
 		}
 	}
 
 	if err := rows.Err(); err != nil {
-		return fmt.Errorf("error iterating jobs: %w", err)
+		return fmt.Errorf("error iterating runs: %w", err)
+	}
+
+	return nil
+}
+
+// checkEscalations notifies the next steps of escalations that are due.
+func (jc *JobChecker) checkEscalations(jobID string) error {
+	query := `
+		SELECT job_id
+		FROM escalations
+		WHERE acked_at IS NULL AND next_at <= $1
+		AND ($2 = '' OR job_id = $2)
+	`
+
+	now := time.Now().UTC()
+	rows, err := jc.db.db.Query(query, now, jobID)
+	if err != nil {
+		return fmt.Errorf("error querying escalations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("error scanning escalation: %w", err)
+		}
+
+		if err := jc.escalateJob(id, now); err != nil {
+			jc.logger.Printf("Error escalating alert of job %s: %v", id, err)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating escalations: %w", err)
 	}
 
 	return nil
 }
 
-func (jc *JobChecker) markJobMissing(jobID, jobName, userID string) error {
+func (jc *JobChecker) escalateJob(jobID string, now time.Time) error {
 	tx, err := jc.db.db.Begin()
 	if err != nil {
 		return fmt.Errorf("error starting transaction: %w", err)
 	}
 
-	_, err = tx.Exec(`
-		UPDATE jobs
-		SET status = $1, updated_at = $2
-		WHERE id = $3 AND status != $1
-	`, models.StatusMissing, time.Now().UTC(), jobID)
+	if err := advanceEscalation(tx, jobID, now); err != nil {
+		tx.Rollback()
+		return err
+	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// checkReminders repeats the alerts of jobs that are still down when their
+// reminder interval passed.
+func (jc *JobChecker) checkReminders(jobID string) error {
+	reminders, err := dueReminders(jc.db.db, jobID)
 	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("error updating job status: %w", err)
+		return err
 	}
 
-	eventID := uuid.New().String()
 	now := time.Now().UTC()
+	for id, r := range reminders {
+		if r.dueAt.After(now) {
+			continue
+		}
+		if err := jc.remindJob(id, now); err != nil {
+			jc.logger.Printf("Error sending reminder for job %s: %v", id, err)
+		}
+	}
 
-	_, err = tx.Exec(`
-		INSERT INTO job_events (id, job_id, type, data, created_at)
-		VALUES ($1, $2, $3, $4, $5)
-	`, eventID, jobID, "miss", "{}", now)
+	return nil
+}
 
+func (jc *JobChecker) remindJob(jobID string, now time.Time) error {
+	tx, err := jc.db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	reminded, err := remindJob(tx, jobID, now)
 	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("error creating event: %w", err)
+		return err
 	}
 
-	notificationID := uuid.New().String()
-	message := fmt.Sprintf("Job '%s' has missed its scheduled run time", jobName)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
 
-	_, err = tx.Exec(`
-		INSERT INTO notifications (id, user_id, job_id, message, type, status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, notificationID, userID, jobID, message, "email", "pending", now)
+	if reminded {
+		jc.logger.Printf("Sent reminder for job %s", jobID)
+	}
+
+	return nil
+}
+
+func (jc *JobChecker) markJob(jobID, userID string, status models.JobStatus, eventType models.JobEventType) error {
+	tx, err := jc.db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	now := time.Now().UTC()
+
+	var previousStatus models.JobStatus
+	err = tx.QueryRow(`
+		UPDATE jobs j
+		SET status = $1, updated_at = $2
+		FROM (SELECT id, status FROM jobs WHERE id = $3 FOR UPDATE) old
+		WHERE j.id = old.id AND old.status != $1
+		RETURNING old.status
+	`, status, now, jobID).Scan(&previousStatus)
 
 	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("error creating notification: %w", err)
+		// another ping or check may have changed the job in the meantime
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("error updating job status: %w", err)
+	}
+
+	eventID, err := createEvent(tx, jobID, eventType, "{}", now)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := createNotification(tx, userID, jobID, eventID, previousStatus, now); err != nil {
+		tx.Rollback()
+		return err
 	}
 
 	err = tx.Commit()
@@ -136,7 +464,7 @@ func (jc *JobChecker) markJobMissing(jobID, jobName, userID string) error {
 		return fmt.Errorf("error committing transaction: %w", err)
 	}
 
-	jc.logger.Printf("Job %s marked as missing", jobID)
+	jc.logger.Printf("Job %s marked as %s", jobID, status)
 
 	return nil
 }
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Leader elects a single replica through a session level Postgres advisory
+// lock. The lock lives as long as the dedicated connection that took it, so
+// a crashed replica releases it automatically.
+type Leader struct {
+	db   *sql.DB
+	key  int64
+	conn *sql.Conn
+}
+
+func NewLeader(database *Database, key int64) *Leader {
+	return &Leader{
+		db:  database.db,
+		key: key,
+	}
+}
+
+// TryAcquire takes the lock if no other replica holds it. It reports whether
+// this replica is the leader afterwards.
+func (l *Leader) TryAcquire(ctx context.Context) (bool, error) {
+	if l.conn != nil {
+		return true, nil
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error opening leader connection: %w", err)
+	}
+
+	var acquired bool
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, l.key).Scan(&acquired)
+	if err != nil || !acquired {
+		conn.Close()
+		if err != nil {
+			return false, fmt.Errorf("error acquiring advisory lock: %w", err)
+		}
+		return false, nil
+	}
+
+	l.conn = conn
+	return true, nil
+}
+
+// Check verifies that the connection holding the lock is still alive. When it
+// is not, leadership is lost and the lock has to be acquired again.
+func (l *Leader) Check(ctx context.Context) error {
+	if l.conn == nil {
+		return fmt.Errorf("not the leader")
+	}
+
+	if err := l.conn.PingContext(ctx); err != nil {
+		l.conn.Close()
+		l.conn = nil
+		return fmt.Errorf("lost leader connection: %w", err)
+	}
+
+	return nil
+}
+
+func (l *Leader) Release() {
+	if l.conn == nil {
+		return
+	}
+
+	l.conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, l.key)
+	l.conn.Close()
+	l.conn = nil
+}
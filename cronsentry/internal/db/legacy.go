@@ -0,0 +1,102 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// legacyUserID is the development user everything belonged to before there
+// were accounts. It cannot sign in anymore, its data is claimed by a real user
+// with ClaimLegacyData.
+const legacyUserID = "test-user"
+
+// lockedPassword is the password hash of the development user. Like
+// noPassword it is not a valid bcrypt hash, but unlike the hash of SSO users
+// it does not let an SSO login with the same email take over the user.
+const lockedPassword = "*"
+
+// legacyTables are the tables with rows owned by a user, other than jobs and
+// templates which need their conflicts resolved first.
+var legacyTables = []string{"projects", "channels", "escalation_policies", "escalations", "notifications"}
+
+// ClaimLegacyData moves the jobs, runs, projects, channels, templates, escalation
+// policies and notifications of the development user to the user with the
+// given email, and removes the development user. Jobs whose slug the user
+// already uses get the start of their ID appended, templates the user already
+// overrides are dropped. It returns false when there is nothing to claim.
+func (d *Database) ClaimLegacyData(email string) (bool, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var legacyID string
+	err = tx.QueryRow(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, legacyUserID).Scan(&legacyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("error querying legacy user: %w", err)
+	}
+
+	var userID string
+	err = tx.QueryRow(`SELECT id FROM users WHERE email = $1`, strings.ToLower(email)).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, fmt.Errorf("no user with email %s", email)
+		}
+		return false, fmt.Errorf("error querying user: %w", err)
+	}
+
+	if userID == legacyUserID {
+		return false, fmt.Errorf("%s is the legacy user itself", email)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE jobs j
+		SET slug = left(j.slug, 91) || '-' || left(j.id, 8)
+		WHERE j.user_id = $1
+		  AND EXISTS (SELECT 1 FROM jobs o WHERE o.user_id = $2 AND o.slug = j.slug)
+	`, legacyUserID, userID)
+	if err != nil {
+		return false, fmt.Errorf("error renaming job slugs: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE jobs SET user_id = $1 WHERE user_id = $2`, userID, legacyUserID); err != nil {
+		return false, fmt.Errorf("error claiming jobs: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM templates t
+		WHERE t.user_id = $1
+		  AND EXISTS (
+			SELECT 1 FROM templates o
+			WHERE o.user_id = $2 AND o.channel = t.channel AND o.event_type = t.event_type AND o.part = t.part
+		  )
+	`, legacyUserID, userID)
+	if err != nil {
+		return false, fmt.Errorf("error deleting overridden templates: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE templates SET user_id = $1 WHERE user_id = $2`, userID, legacyUserID); err != nil {
+		return false, fmt.Errorf("error claiming templates: %w", err)
+	}
+
+	for _, table := range legacyTables {
+		if _, err := tx.Exec(`UPDATE `+table+` SET user_id = $1 WHERE user_id = $2`, userID, legacyUserID); err != nil {
+			return false, fmt.Errorf("error claiming %s: %w", table, err)
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM users WHERE id = $1`, legacyUserID); err != nil {
+		return false, fmt.Errorf("error deleting legacy user: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return true, nil
+}
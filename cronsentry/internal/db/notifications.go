@@ -0,0 +1,95 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+const notificationColumns = `id, user_id, job_id, event_id, channel_id, type, status, attempts,
+	next_attempt_at, COALESCE(last_error, ''), sent_at, created_at`
+
+func scanNotification(row rowScanner) (*models.Notification, error) {
+	var notification models.Notification
+	err := row.Scan(
+		&notification.ID, &notification.UserID, &notification.JobID, &notification.EventID,
+		&notification.ChannelID, &notification.Type, &notification.Status,
+		&notification.Attempts, &notification.NextAttemptAt, &notification.LastError,
+		&notification.SentAt, &notification.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+func (d *Database) GetNotification(id string) (*models.Notification, error) {
+	query := `SELECT ` + notificationColumns + `
+		FROM notifications
+		WHERE id = $1
+	`
+
+	notification, err := scanNotification(d.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error querying notification: %w", err)
+	}
+
+	return notification, nil
+}
+
+// ListDeadNotifications returns the dead-lettered notifications of a user,
+// newest first.
+func (d *Database) ListDeadNotifications(userID string, limit int) ([]*models.Notification, error) {
+	query := `SELECT ` + notificationColumns + `
+		FROM notifications
+		WHERE user_id = $1 AND status = $2
+		ORDER BY created_at DESC
+		LIMIT $3
+	`
+	rows, err := d.db.Query(query, userID, models.NotificationDead, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []*models.Notification
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning notification row: %w", err)
+		}
+		notifications = append(notifications, notification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notification rows: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// RetryNotification queues a dead-lettered notification again with a fresh
+// attempt budget. It reports false when the notification is not dead.
+func (d *Database) RetryNotification(id string) (bool, error) {
+	query := `
+		UPDATE notifications
+		SET status = $1, attempts = 0, next_attempt_at = NULL
+		WHERE id = $2 AND status = $3
+	`
+
+	result, err := d.db.Exec(query, models.NotificationPending, id, models.NotificationDead)
+	if err != nil {
+		return false, fmt.Errorf("error retrying notification: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error retrying notification: %w", err)
+	}
+
+	return rows > 0, nil
+}
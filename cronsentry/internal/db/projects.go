@@ -0,0 +1,218 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+// pingKeyPrefix marks ping keys, so they are easy to spot in crontabs and by
+// secret scanners.
+const pingKeyPrefix = "pk_"
+
+const projectColumns = `id, user_id, name, ping_key_prefix, created_at, updated_at`
+
+func scanProject(row rowScanner) (*models.Project, error) {
+	var project models.Project
+	err := row.Scan(&project.ID, &project.UserID, &project.Name, &project.PingKeyPrefix, &project.CreatedAt, &project.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (d *Database) GetProject(id string) (*models.Project, error) {
+	query := `SELECT ` + projectColumns + `
+		FROM projects
+		WHERE id = $1
+	`
+
+	project, err := scanProject(d.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error querying project: %w", err)
+	}
+
+	return project, nil
+}
+
+func (d *Database) ListProjectsByUser(userID string) ([]*models.Project, error) {
+	query := `SELECT ` + projectColumns + `
+		FROM projects
+		WHERE user_id = $1
+		ORDER BY name
+	`
+	rows, err := d.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying projects: %w", err)
+	}
+	defer rows.Close()
+
+	var projects []*models.Project
+	for rows.Next() {
+		project, err := scanProject(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning project row: %w", err)
+		}
+		projects = append(projects, project)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating project rows: %w", err)
+	}
+
+	return projects, nil
+}
+
+func (d *Database) CreateProject(project *models.Project) error {
+	if project.ID == "" {
+		project.ID = uuid.New().String()
+	}
+
+	now := time.Now().UTC()
+	project.CreatedAt = now
+	project.UpdatedAt = now
+
+	query := `INSERT INTO projects (` + projectColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := d.db.Exec(query, project.ID, project.UserID, project.Name, project.PingKeyPrefix, project.CreatedAt, project.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating project: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) UpdateProject(project *models.Project) error {
+	project.UpdatedAt = time.Now().UTC()
+
+	query := `
+		UPDATE projects
+		SET name = $1, updated_at = $2
+		WHERE id = $3 AND user_id = $4
+	`
+	result, err := d.db.Exec(query, project.Name, project.UpdatedAt, project.ID, project.UserID)
+	if err != nil {
+		return fmt.Errorf("error updating project: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("project not found or not owned by user")
+	}
+
+	return nil
+}
+
+// DeleteProject removes the project and its ping key. Its jobs are kept
+// without a project.
+func (d *Database) DeleteProject(id string) error {
+	query := `DELETE FROM projects WHERE id = $1`
+	result, err := d.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("error deleting project: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("project not found")
+	}
+
+	return nil
+}
+
+// RotatePingKey gives the project a new ping key and sets project.PingKey.
+// Only its hash is stored, so this is the only time the key can be shown.
+// URLs with the previous key stop working right away.
+func (d *Database) RotatePingKey(project *models.Project) error {
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	key := pingKeyPrefix + token
+	prefix := key[:len(pingKeyPrefix)+6]
+	now := time.Now().UTC()
+
+	_, err = d.db.Exec(`
+		UPDATE projects
+		SET ping_key_prefix = $1, ping_key_hash = $2, updated_at = $3
+		WHERE id = $4
+	`, prefix, hashToken(key), now, project.ID)
+	if err != nil {
+		return fmt.Errorf("error rotating ping key: %w", err)
+	}
+
+	project.PingKey = key
+	project.PingKeyPrefix = &prefix
+	project.UpdatedAt = now
+	return nil
+}
+
+// DeletePingKey disables pinging the jobs of the project with a ping key.
+// Pings by job ID keep working.
+func (d *Database) DeletePingKey(projectID string) error {
+	_, err := d.db.Exec(`
+		UPDATE projects
+		SET ping_key_prefix = NULL, ping_key_hash = NULL, updated_at = $1
+		WHERE id = $2
+	`, time.Now().UTC(), projectID)
+	if err != nil {
+		return fmt.Errorf("error deleting ping key: %w", err)
+	}
+	return nil
+}
+
+// GetPingKeyProject returns the project a ping key belongs to, or nil when
+// the key is unknown.
+func (d *Database) GetPingKeyProject(key string) (*models.Project, error) {
+	query := `SELECT ` + projectColumns + `
+		FROM projects
+		WHERE ping_key_hash = $1
+	`
+
+	project, err := scanProject(d.db.QueryRow(query, hashToken(key)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error querying ping key: %w", err)
+	}
+
+	return project, nil
+}
+
+// GetProjectJob returns the job of a project with the given slug. Job IDs are
+// accepted as well, so ping URLs made before slugs existed keep working.
+func (d *Database) GetProjectJob(projectID, slug string) (*models.Job, error) {
+	query := `SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE project_id = $1 AND (slug = $2 OR id = $2)
+		ORDER BY slug = $2 DESC
+		LIMIT 1
+	`
+
+	job, err := scanJob(d.db.QueryRow(query, projectID, slug))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error querying job: %w", err)
+	}
+
+	return job, nil
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+type reminder struct {
+	dueAt time.Time
+	sent  int // reminders sent so far
+}
+
+// dueReminders returns when each job that is still down has to be reminded
+// next, or only the given job when jobID is not empty. It is derived from the
+// notifications and reminders since the incident started, so the cadence
+// survives restarts. Jobs that used up their reminders or whose alert was
+// acknowledged are left out.
+func dueReminders(q querier, jobID string) (map[string]reminder, error) {
+	query := `
+		SELECT j.id, j.reminder_interval, notified.last_at, reminded.last_at, reminded.reminders
+		FROM jobs j
+		CROSS JOIN LATERAL (
+			SELECT MIN(created_at) AS started_at
+			FROM job_events
+			WHERE job_id = j.id AND type = ANY($2) AND created_at > COALESCE((
+				SELECT MAX(created_at) FROM job_events WHERE job_id = j.id AND type = $3
+			), '-infinity')
+		) incident
+		CROSS JOIN LATERAL (
+			SELECT MAX(created_at) AS last_at
+			FROM notifications
+			WHERE job_id = j.id AND created_at >= incident.started_at
+		) notified
+		CROSS JOIN LATERAL (
+			SELECT MAX(created_at) AS last_at, COUNT(*) AS reminders
+			FROM job_events
+			WHERE job_id = j.id AND type = $1 AND created_at >= incident.started_at
+		) reminded
+		WHERE j.reminder_interval > 0 AND j.status = ANY($4)
+		AND notified.last_at IS NOT NULL AND reminded.reminders < j.max_reminders
+		AND NOT EXISTS (SELECT 1 FROM escalations WHERE job_id = j.id AND acked_at IS NOT NULL)
+		AND ($5 = '' OR j.id = $5)
+	`
+
+	alertTypes := []string{string(models.TypeMiss), string(models.TypeFail), string(models.TypeLongRunning)}
+	downStatuses := []string{string(models.StatusMissing), string(models.StatusFailed)}
+
+	rows, err := q.Query(query, models.TypeReminder, pq.Array(alertTypes), models.TypeRecovery, pq.Array(downStatuses), jobID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying reminders: %w", err)
+	}
+	defer rows.Close()
+
+	reminders := make(map[string]reminder)
+	for rows.Next() {
+		var id string
+		var interval models.Duration
+		var notifiedAt time.Time
+		var remindedAt sql.NullTime
+		var sent int
+		if err := rows.Scan(&id, &interval, &notifiedAt, &remindedAt, &sent); err != nil {
+			return nil, fmt.Errorf("error scanning reminder: %w", err)
+		}
+
+		// a reminder counts even when it queued no notification, e.g. because
+		// the channels of the escalation step are gone
+		last := notifiedAt
+		if remindedAt.Valid && remindedAt.Time.After(last) {
+			last = remindedAt.Time
+		}
+		reminders[id] = reminder{dueAt: last.Add(interval.Duration()), sent: sent}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reminders: %w", err)
+	}
+
+	return reminders, nil
+}
+
+// remindJob repeats the alert of a job that is still down, if a reminder is
+// still due once the job is locked.
+func remindJob(tx *sql.Tx, jobID string, now time.Time) (bool, error) {
+	var userID string
+	var status models.JobStatus
+	err := tx.QueryRow(`
+		SELECT user_id, status
+		FROM jobs
+		WHERE id = $1
+		FOR UPDATE
+	`, jobID).Scan(&userID, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("error querying job: %w", err)
+	}
+
+	reminders, err := dueReminders(tx, jobID)
+	if err != nil {
+		return false, err
+	}
+
+	r, ok := reminders[jobID]
+	if !ok || r.dueAt.After(now) {
+		return false, nil
+	}
+
+	number := r.sent + 1
+	data, err := json.Marshal(map[string]int{"reminder": number})
+	if err != nil {
+		return false, fmt.Errorf("error encoding event data: %w", err)
+	}
+
+	eventID, err := createEvent(tx, jobID, models.TypeReminder, string(data), now)
+	if err != nil {
+		return false, err
+	}
+
+	if err := createNotification(tx, userID, jobID, eventID, status, now); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
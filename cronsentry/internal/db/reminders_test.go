@@ -0,0 +1,73 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var reminderColumns = []string{"id", "reminder_interval", "notified_at", "reminded_at", "reminders"}
+
+func TestDueReminders(t *testing.T) {
+	alertedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		notifiedAt time.Time
+		remindedAt any
+		sent       int
+		want       time.Time
+	}{
+		{name: "after the alert", notifiedAt: alertedAt, want: alertedAt.Add(time.Hour)},
+		{name: "after a reminder", notifiedAt: alertedAt.Add(time.Hour), remindedAt: alertedAt.Add(time.Hour), sent: 1, want: alertedAt.Add(2 * time.Hour)},
+		{name: "after a reminder that queued nothing", notifiedAt: alertedAt, remindedAt: alertedAt.Add(time.Hour), sent: 1, want: alertedAt.Add(2 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, mock := newMockDatabase(t)
+
+			mock.ExpectQuery(`FROM jobs`).WillReturnRows(
+				sqlmock.NewRows(reminderColumns).AddRow("job-1", int64(3600), tt.notifiedAt, tt.remindedAt, tt.sent))
+
+			reminders, err := dueReminders(database.db, "job-1")
+			if err != nil {
+				t.Fatalf("dueReminders() error = %v", err)
+			}
+			if got := reminders["job-1"]; !got.dueAt.Equal(tt.want) || got.sent != tt.sent {
+				t.Errorf("dueReminders() = %+v, want due at %s after %d reminders", got, tt.want, tt.sent)
+			}
+		})
+	}
+}
+
+// A reminder whose step channels are gone queues no notification, the next
+// one is still an interval away rather than due on every check.
+func TestRemindJobAfterReminderThatQueuedNothing(t *testing.T) {
+	database, mock := newMockDatabase(t)
+
+	alertedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	remindedAt := alertedAt.Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FOR UPDATE`).WithArgs("job-1").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "status"}).AddRow("user-1", "missing"))
+	mock.ExpectQuery(`FROM jobs`).WillReturnRows(
+		sqlmock.NewRows(reminderColumns).AddRow("job-1", int64(3600), alertedAt, remindedAt, 1))
+	mock.ExpectRollback()
+
+	tx, err := database.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	reminded, err := remindJob(tx, "job-1", remindedAt.Add(5*time.Second))
+	if err != nil {
+		t.Fatalf("remindJob() error = %v", err)
+	}
+	if reminded {
+		t.Error("remindJob() sent another reminder right after the last one")
+	}
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+// recordStart opens a new run of the job. Runs still open from earlier starts
+// are abandoned, as their finish ping is never going to arrive, and would
+// otherwise be closed by the finish of the new run.
+func (d *Database) recordStart(jobID, data string, now time.Time) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE job_runs
+		SET status = $1, finished_at = $2
+		WHERE job_id = $3 AND finished_at IS NULL
+	`, models.RunAbandoned, now, jobID)
+
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error abandoning open runs: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO job_runs (id, job_id, status, started_at)
+		VALUES ($1, $2, $3, $4)
+	`, uuid.New().String(), jobID, models.RunRunning, now)
+
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error creating run: %w", err)
+	}
+
+	if _, err := createEvent(tx, jobID, models.TypeStart, data, now); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	d.notifyJobChanged(jobID)
+
+	return nil
+}
+
+// finishRun closes the most recent open run of the job. A finish without a
+// preceding start ping is still recorded, just without a start time.
+func finishRun(tx *sql.Tx, jobID string, status models.JobRunStatus, now time.Time) error {
+	var runID string
+	var startedAt time.Time
+	err := tx.QueryRow(`
+		SELECT id, started_at
+		FROM job_runs
+		WHERE job_id = $1 AND finished_at IS NULL
+		ORDER BY started_at DESC
+		LIMIT 1
+		FOR UPDATE
+	`, jobID).Scan(&runID, &startedAt)
+
+	if err == sql.ErrNoRows {
+		_, err = tx.Exec(`
+			INSERT INTO job_runs (id, job_id, status, finished_at)
+			VALUES ($1, $2, $3, $4)
+		`, uuid.New().String(), jobID, status, now)
+
+		if err != nil {
+			return fmt.Errorf("error creating run: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error querying open run: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE job_runs
+		SET status = $1, finished_at = $2, duration_ms = $3
+		WHERE id = $4
+	`, status, now, now.Sub(startedAt).Milliseconds(), runID)
+
+	if err != nil {
+		return fmt.Errorf("error finishing run: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) ListRunsByJob(jobID string, limit int) ([]*models.JobRun, error) {
+	query := `
+		SELECT id, job_id, status, started_at, finished_at, duration_ms
+		FROM job_runs
+		WHERE job_id = $1
+		ORDER BY COALESCE(started_at, finished_at) DESC
+		LIMIT $2
+	`
+	rows, err := d.db.Query(query, jobID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*models.JobRun
+	for rows.Next() {
+		var run models.JobRun
+		err := rows.Scan(
+			&run.ID, &run.JobID, &run.Status,
+			&run.StartedAt, &run.FinishedAt, &run.DurationMs,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning run row: %w", err)
+		}
+		runs = append(runs, &run)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating run rows: %w", err)
+	}
+
+	return runs, nil
+}
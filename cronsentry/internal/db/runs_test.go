@@ -0,0 +1,113 @@
+package db
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+// capture matches any argument and keeps it, so tests can check what was
+// written rather than how the query is spelled.
+type capture struct {
+	value driver.Value
+}
+
+func (c *capture) Match(v driver.Value) bool {
+	c.value = v
+	return true
+}
+
+func TestRecordStartAbandonsOpenRun(t *testing.T) {
+	database, mock := newMockDatabase(t)
+	now := time.Now().UTC()
+
+	var abandonStatus, abandonedAt, abandonJob capture
+	var runJob, runStatus, runStartedAt capture
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE job_runs`).WithArgs(&abandonStatus, &abandonedAt, &abandonJob).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO job_runs`).WithArgs(sqlmock.AnyArg(), &runJob, &runStatus, &runStartedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO job_events`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`pg_notify`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := database.recordStart("job-1", "{}", now); err != nil {
+		t.Fatalf("recordStart() error = %v", err)
+	}
+
+	if abandonJob.value != "job-1" || abandonStatus.value != string(models.RunAbandoned) || abandonedAt.value != now {
+		t.Errorf("open run closed as %v at %v for %v, want abandoned at %v for job-1",
+			abandonStatus.value, abandonedAt.value, abandonJob.value, now)
+	}
+	if runJob.value != "job-1" || runStatus.value != string(models.RunRunning) || runStartedAt.value != now {
+		t.Errorf("new run %v started at %v for %v, want running at %v for job-1",
+			runStatus.value, runStartedAt.value, runJob.value, now)
+	}
+}
+
+func TestFinishRun(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name         string
+		startedAt    time.Time
+		wantRun      string
+		wantDuration int64
+	}{
+		{name: "pairs with the open run", startedAt: now.Add(-90 * time.Second), wantRun: "run-2", wantDuration: 90000},
+		{name: "finish without a start"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, mock := newMockDatabase(t)
+
+			open := sqlmock.NewRows([]string{"id", "started_at"})
+			if !tt.startedAt.IsZero() {
+				open.AddRow(tt.wantRun, tt.startedAt)
+			}
+
+			var status, finishedAt, duration, runID capture
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`FROM job_runs`).WithArgs("job-1").WillReturnRows(open)
+			if tt.startedAt.IsZero() {
+				mock.ExpectExec(`INSERT INTO job_runs`).WithArgs(sqlmock.AnyArg(), "job-1", &status, &finishedAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			} else {
+				mock.ExpectExec(`UPDATE job_runs`).WithArgs(&status, &finishedAt, &duration, &runID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			mock.ExpectCommit()
+
+			tx, err := database.db.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := finishRun(tx, "job-1", models.RunSuccess, now); err != nil {
+				t.Fatalf("finishRun() error = %v", err)
+			}
+			if err := tx.Commit(); err != nil {
+				t.Fatal(err)
+			}
+
+			if status.value != string(models.RunSuccess) || finishedAt.value != now {
+				t.Errorf("run finished as %v at %v, want success at %v", status.value, finishedAt.value, now)
+			}
+			if tt.startedAt.IsZero() {
+				return
+			}
+			if runID.value != tt.wantRun {
+				t.Errorf("finished run %v, want %s", runID.value, tt.wantRun)
+			}
+			if duration.value != tt.wantDuration {
+				t.Errorf("run duration = %v ms, want %d", duration.value, tt.wantDuration)
+			}
+		})
+	}
+}
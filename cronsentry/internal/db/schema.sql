@@ -6,13 +6,66 @@ CREATE TABLE IF NOT EXISTS users (
     created_at TIMESTAMPTZ NOT NULL,
     updated_at TIMESTAMPTZ NOT NULL
 );
+
+-- id is the SHA-256 of the session token, the token itself is not stored
+CREATE TABLE IF NOT EXISTS sessions (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
  
+-- accounts at the SSO provider, linked to the user they sign in as
+CREATE TABLE IF NOT EXISTS user_identities (
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE TABLE IF NOT EXISTS teams (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS team_members (
+    team_id VARCHAR(36) NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL DEFAULT 'member',
+    PRIMARY KEY (team_id, user_id)
+);
+
+-- key_hash is the SHA-256 of the key, the key itself is not stored
+CREATE TABLE IF NOT EXISTS api_keys (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- ping_key_hash is the SHA-256 of the ping key, the key itself is not stored
+CREATE TABLE IF NOT EXISTS projects (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    ping_key_prefix VARCHAR(20),
+    ping_key_hash VARCHAR(64) UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
 CREATE TABLE IF NOT EXISTS jobs (
     id VARCHAR(36) PRIMARY KEY,
     name VARCHAR(255) NOT NULL,
     description TEXT,
     schedule VARCHAR(255) NOT NULL,
-    grace_time INTEGER NOT NULL DEFAULT 10,
+    grace_period INTEGER NOT NULL DEFAULT 600, -- seconds
     last_ping TIMESTAMPTZ,
     next_expect TIMESTAMPTZ,
     status VARCHAR(50) NOT NULL DEFAULT 'healthy',
@@ -29,23 +82,159 @@ CREATE TABLE IF NOT EXISTS job_events (
     created_at TIMESTAMPTZ NOT NULL
 );
 
+CREATE TABLE IF NOT EXISTS job_runs (
+    id VARCHAR(36) PRIMARY KEY,
+    job_id VARCHAR(36) NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    status VARCHAR(50) NOT NULL DEFAULT 'running',
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    duration_ms BIGINT
+);
+
+CREATE TABLE IF NOT EXISTS channels (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    config JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- user overrides of the default notification templates
+CREATE TABLE IF NOT EXISTS templates (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    channel VARCHAR(50) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    part VARCHAR(20) NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (user_id, channel, event_type, part)
+);
+
+CREATE TABLE IF NOT EXISTS escalation_policies (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    steps JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- escalation of the current alert of a job, removed when the job recovers
+CREATE TABLE IF NOT EXISTS escalations (
+    job_id VARCHAR(36) PRIMARY KEY REFERENCES jobs(id) ON DELETE CASCADE,
+    policy_id VARCHAR(36) NOT NULL REFERENCES escalation_policies(id) ON DELETE CASCADE,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event_id VARCHAR(36) REFERENCES job_events(id) ON DELETE SET NULL,
+    previous_status VARCHAR(50),
+    step INTEGER NOT NULL DEFAULT 0, -- next step to notify
+    next_at TIMESTAMPTZ, -- NULL once all steps were notified or the alert was acknowledged
+    started_at TIMESTAMPTZ NOT NULL,
+    acked_at TIMESTAMPTZ,
+    acked_by VARCHAR(36)
+);
+
 CREATE TABLE IF NOT EXISTS notifications (
     id VARCHAR(36) PRIMARY KEY,
     user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     job_id VARCHAR(36) REFERENCES jobs(id) ON DELETE CASCADE,
-    message TEXT NOT NULL,
     type VARCHAR(50) NOT NULL,
     status VARCHAR(50) NOT NULL DEFAULT 'pending', 
     sent_at TIMESTAMPTZ,
     created_at TIMESTAMPTZ NOT NULL
 );
 
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT 'cron';
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS period INTEGER; -- seconds, for period jobs
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS max_runtime INTEGER NOT NULL DEFAULT 0; -- seconds, 0 disables
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS reminder_interval INTEGER NOT NULL DEFAULT 0; -- seconds, 0 disables
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS max_reminders INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS escalation_policy_id VARCHAR(36) REFERENCES escalation_policies(id) ON DELETE SET NULL;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS slug VARCHAR(100);
+
+-- jobs created before slugs existed get one from their name, duplicates of
+-- the same user are told apart by the start of their ID
+UPDATE jobs j
+SET slug = s.base || CASE WHEN s.n > 1 THEN '-' || left(j.id, 8) ELSE '' END
+FROM (
+    SELECT id, base, ROW_NUMBER() OVER (PARTITION BY user_id, base ORDER BY created_at, id) AS n
+    FROM (
+        SELECT id, user_id, created_at,
+               COALESCE(NULLIF(trim(BOTH '-' FROM regexp_replace(lower(left(name, 90)), '[^a-z0-9]+', '-', 'g')), ''), 'job') AS base
+        FROM jobs
+        WHERE slug IS NULL
+    ) named
+) s
+WHERE j.id = s.id;
+ALTER TABLE jobs ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS project_id VARCHAR(36) REFERENCES projects(id) ON DELETE SET NULL;
+
+-- grace_time used to be stored in minutes
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'jobs' AND column_name = 'grace_time') THEN
+        ALTER TABLE jobs ADD COLUMN IF NOT EXISTS grace_period INTEGER NOT NULL DEFAULT 600;
+        UPDATE jobs SET grace_period = grace_time * 60;
+        ALTER TABLE jobs DROP COLUMN grace_time;
+    END IF;
+END $$;
+
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS event_id VARCHAR(36) REFERENCES job_events(id) ON DELETE SET NULL;
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS channel_id VARCHAR(36) REFERENCES channels(id) ON DELETE CASCADE;
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS previous_status VARCHAR(50); -- job status before the event
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS downtime INTEGER; -- seconds, for recovery notifications
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ; -- NULL means right away
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS last_error TEXT;
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ; -- lease of a notification being sent
+
+-- the text of notifications is rendered from templates when they are sent
+ALTER TABLE notifications DROP COLUMN IF EXISTS message;
+ALTER TABLE escalations DROP COLUMN IF EXISTS message;
+
+-- wakes the notification processors when a notification is ready to be sent
+CREATE OR REPLACE FUNCTION notify_notification_queued() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('cronsentry_notification_queued', '');
+    RETURN NULL;
+END $$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS notification_queued ON notifications;
+CREATE TRIGGER notification_queued
+    AFTER INSERT OR UPDATE OF status ON notifications
+    FOR EACH ROW WHEN (NEW.status = 'pending' AND NEW.next_attempt_at IS NULL)
+    EXECUTE FUNCTION notify_notification_queued();
+
+-- failed notifications used to be final, they are dead letters now
+UPDATE notifications SET status = 'dead' WHERE status = 'failed';
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+CREATE INDEX IF NOT EXISTS idx_team_members_user_id ON team_members(user_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
+CREATE INDEX IF NOT EXISTS idx_projects_user_id ON projects(user_id);
 CREATE INDEX IF NOT EXISTS idx_jobs_user_id ON jobs(user_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_project_id ON jobs(project_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_user_slug ON jobs(user_id, slug);
 CREATE INDEX IF NOT EXISTS idx_job_events_job_id ON job_events(job_id);
 CREATE INDEX IF NOT EXISTS idx_job_events_created_at ON job_events(created_at);
+CREATE INDEX IF NOT EXISTS idx_job_runs_job_id ON job_runs(job_id, started_at);
+CREATE INDEX IF NOT EXISTS idx_channels_user_id ON channels(user_id);
+CREATE INDEX IF NOT EXISTS idx_escalation_policies_user_id ON escalation_policies(user_id);
+CREATE INDEX IF NOT EXISTS idx_escalations_next_at ON escalations(next_at);
 CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id);
 CREATE INDEX IF NOT EXISTS idx_notifications_job_id ON notifications(job_id);
+CREATE INDEX IF NOT EXISTS idx_notifications_pending ON notifications(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_notifications_sending ON notifications(locked_until) WHERE status = 'sending';
 
-INSERT INTO users (id, email, name, password_hash, created_at, updated_at)
-VALUES ('test-user', 'test@example.com', 'Test User', '$2a$10$vI8aWBnW3fID.ZQ4/zo1G.q1lRps.9cGLcZEiGDMVr5yUP1KUOYTa', NOW(), NOW())
-ON CONFLICT (id) DO NOTHING; 
\ No newline at end of file
+-- the development user used to be seeded with a well known password, lock it
+-- now that passwords are checked, LEGACY_DATA_OWNER moves its data to a real
+-- account. It was locked with the hash of SSO users at first, which let an SSO
+-- login with its email take it over.
+UPDATE users SET password_hash = '*'
+WHERE password_hash = '$2a$10$vI8aWBnW3fID.ZQ4/zo1G.q1lRps.9cGLcZEiGDMVr5yUP1KUOYTa'
+   OR (id = 'test-user' AND password_hash = '!');
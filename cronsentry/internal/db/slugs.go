@@ -0,0 +1,47 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// uniqueSlug returns base, or base with the first free numeric suffix when
+// another job of the user already uses it.
+func (d *Database) uniqueSlug(userID, base string) (string, error) {
+	rows, err := d.db.Query(`
+		SELECT slug
+		FROM jobs
+		WHERE user_id = $1 AND (slug = $2 OR slug LIKE $3)
+	`, userID, base, base+"-%")
+	if err != nil {
+		return "", fmt.Errorf("error querying slugs: %w", err)
+	}
+	defer rows.Close()
+
+	taken := make(map[string]bool)
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return "", fmt.Errorf("error scanning slug: %w", err)
+		}
+		taken[slug] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("error iterating slugs: %w", err)
+	}
+
+	slug := base
+	for n := 2; taken[slug]; n++ {
+		slug = base + "-" + strconv.Itoa(n)
+	}
+	return slug, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
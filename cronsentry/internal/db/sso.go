@@ -0,0 +1,244 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+// noPassword is the password hash of users created by SSO. It is not a valid
+// bcrypt hash, so they cannot log in with a password.
+const noPassword = "!"
+
+// ErrIdentityTaken is returned when an SSO identity is already linked to
+// another user.
+var ErrIdentityTaken = errors.New("identity already linked to another user")
+
+// ProvisionUser returns the user an SSO identity signs in as. On the first
+// login of the identity a new user is created, or it is linked to the user
+// with the same email if that user has no password and the provider verified
+// the email. A user with a password has to link the identity from their own
+// session with LinkIdentity, otherwise ErrEmailTaken is returned, so whoever
+// controls an account with the same email at the provider cannot take over a
+// password account.
+func (d *Database) ProvisionUser(issuer, subject, email, name string, emailVerified bool) (*models.User, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	user, err := identityUser(tx, issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if user != nil {
+		if name != "" && name != user.Name {
+			user.Name = name
+			user.UpdatedAt = now
+			_, err := tx.Exec(`UPDATE users SET name = $1, updated_at = $2 WHERE id = $3`, user.Name, user.UpdatedAt, user.ID)
+			if err != nil {
+				return nil, fmt.Errorf("error updating user: %w", err)
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("error committing transaction: %w", err)
+		}
+		return user, nil
+	}
+
+	email = strings.ToLower(email)
+	userID := uuid.New().String()
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+
+	// a user registering or signing in with the same email at the same time
+	// wins the insert, the email check below then applies to them
+	_, err = tx.Exec(`
+		INSERT INTO users (`+userColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (email) DO NOTHING
+	`, userID, email, name, noPassword, now, now)
+	if err != nil {
+		return nil, fmt.Errorf("error creating user: %w", err)
+	}
+
+	user, err = scanUser(tx.QueryRow(`SELECT `+userColumns+` FROM users WHERE email = $1 FOR UPDATE`, email))
+	if err != nil {
+		return nil, fmt.Errorf("error querying user: %w", err)
+	}
+
+	// a concurrent first login of the same identity may have linked it while
+	// the insert waited for it, rolling back drops the user created for nothing
+	linked, err := identityUser(tx, issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+	if linked != nil {
+		return linked, nil
+	}
+
+	if user.ID != userID && (!emailVerified || user.Password != noPassword) {
+		return nil, ErrEmailTaken
+	}
+
+	// a login of the identity with another email may still have linked it in
+	// the meantime, the first link wins
+	_, err = tx.Exec(`
+		INSERT INTO user_identities (issuer, subject, user_id, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (issuer, subject) DO NOTHING
+	`, issuer, subject, user.ID, now)
+	if err != nil {
+		return nil, fmt.Errorf("error linking identity: %w", err)
+	}
+
+	linked, err = identityUser(tx, issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+	if linked.ID != user.ID {
+		return linked, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return user, nil
+}
+
+// LinkIdentity lets the user sign in with the SSO identity from now on. It
+// returns ErrIdentityTaken when the identity already signs in as another user.
+func (d *Database) LinkIdentity(userID, issuer, subject string) error {
+	_, err := d.db.Exec(`
+		INSERT INTO user_identities (issuer, subject, user_id, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (issuer, subject) DO NOTHING
+	`, issuer, subject, userID, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("error linking identity: %w", err)
+	}
+
+	var linkedID string
+	err = d.db.QueryRow(`SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2`, issuer, subject).Scan(&linkedID)
+	if err != nil {
+		return fmt.Errorf("error querying identity: %w", err)
+	}
+
+	if linkedID != userID {
+		return ErrIdentityTaken
+	}
+
+	return nil
+}
+
+// identityUser returns the user the identity is linked to, or nil.
+func identityUser(tx *sql.Tx, issuer, subject string) (*models.User, error) {
+	user, err := scanUser(tx.QueryRow(`
+		SELECT u.id, u.email, u.name, u.password_hash, u.created_at, u.updated_at
+		FROM user_identities i
+		JOIN users u ON i.user_id = u.id
+		WHERE i.issuer = $1 AND i.subject = $2
+	`, issuer, subject))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error querying identity: %w", err)
+	}
+	return user, nil
+}
+
+// SyncTeams makes the team memberships of a user match the given teams and
+// roles, creating teams that do not exist yet.
+func (d *Database) SyncTeams(userID string, teams map[string]models.TeamRole) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	teamIDs := make([]string, 0, len(teams))
+	for name, role := range teams {
+		_, err := tx.Exec(`
+			INSERT INTO teams (id, name, created_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (name) DO NOTHING
+		`, uuid.New().String(), name, now)
+		if err != nil {
+			return fmt.Errorf("error creating team: %w", err)
+		}
+
+		var teamID string
+		if err := tx.QueryRow(`SELECT id FROM teams WHERE name = $1`, name).Scan(&teamID); err != nil {
+			return fmt.Errorf("error querying team: %w", err)
+		}
+		teamIDs = append(teamIDs, teamID)
+
+		_, err = tx.Exec(`
+			INSERT INTO team_members (team_id, user_id, role)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (team_id, user_id) DO UPDATE SET role = EXCLUDED.role
+		`, teamID, userID, role)
+		if err != nil {
+			return fmt.Errorf("error adding team member: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM team_members
+		WHERE user_id = $1 AND team_id <> ALL($2)
+	`, userID, pq.Array(teamIDs))
+	if err != nil {
+		return fmt.Errorf("error removing team members: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) ListTeamMemberships(userID string) ([]*models.TeamMembership, error) {
+	rows, err := d.db.Query(`
+		SELECT m.team_id, t.name, m.user_id, m.role
+		FROM team_members m
+		JOIN teams t ON m.team_id = t.id
+		WHERE m.user_id = $1
+		ORDER BY t.name
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying team memberships: %w", err)
+	}
+	defer rows.Close()
+
+	var memberships []*models.TeamMembership
+	for rows.Next() {
+		var membership models.TeamMembership
+		if err := rows.Scan(&membership.TeamID, &membership.TeamName, &membership.UserID, &membership.Role); err != nil {
+			return nil, fmt.Errorf("error scanning team membership row: %w", err)
+		}
+		memberships = append(memberships, &membership)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating team membership rows: %w", err)
+	}
+
+	return memberships, nil
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestProvisionUserExistingEmail(t *testing.T) {
+	tests := []struct {
+		name          string
+		password      string
+		emailVerified bool
+		linked        bool
+	}{
+		{name: "password account", password: "$2a$10$abcdefghijklmnopqrstuvABCDEFGHIJKLMNOPQRSTUVWXYZ01234", emailVerified: true},
+		{name: "password account, unverified email", password: "$2a$10$abcdefghijklmnopqrstuvABCDEFGHIJKLMNOPQRSTUVWXYZ01234"},
+		{name: "SSO account, unverified email", password: noPassword},
+		{name: "SSO account", password: noPassword, emailVerified: true, linked: true},
+		{name: "development user", password: lockedPassword, emailVerified: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, mock := newMockDatabase(t)
+
+			now := time.Now().UTC()
+			columns := []string{"id", "email", "name", "password_hash", "created_at", "updated_at"}
+			existing := sqlmock.NewRows(columns).AddRow("user-1", "you@example.com", "You", tt.password, now, now)
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`FROM user_identities`).WithArgs("https://issuer", "subject").WillReturnRows(sqlmock.NewRows(columns))
+			mock.ExpectExec(`INSERT INTO users`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(`FROM users WHERE email = \$1 FOR UPDATE`).WithArgs("you@example.com").WillReturnRows(existing)
+			mock.ExpectQuery(`FROM user_identities`).WithArgs("https://issuer", "subject").WillReturnRows(sqlmock.NewRows(columns))
+			if tt.linked {
+				mock.ExpectExec(`INSERT INTO user_identities`).
+					WithArgs("https://issuer", "subject", "user-1", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`FROM user_identities`).WithArgs("https://issuer", "subject").
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("user-1", "you@example.com", "You", tt.password, now, now))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			user, err := database.ProvisionUser("https://issuer", "subject", "You@example.com", "You", tt.emailVerified)
+
+			if tt.linked {
+				if err != nil {
+					t.Fatalf("ProvisionUser() error = %v", err)
+				}
+				if user.ID != "user-1" {
+					t.Errorf("ProvisionUser() user = %q, want user-1", user.ID)
+				}
+			} else if !errors.Is(err, ErrEmailTaken) {
+				t.Fatalf("ProvisionUser() error = %v, want ErrEmailTaken", err)
+			}
+		})
+	}
+}
+
+func TestProvisionUserLinkedByConcurrentLogin(t *testing.T) {
+	database, mock := newMockDatabase(t)
+
+	now := time.Now().UTC()
+	columns := []string{"id", "email", "name", "password_hash", "created_at", "updated_at"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM user_identities`).WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectExec(`INSERT INTO users`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`FROM users WHERE email = \$1 FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("user-1", "you@example.com", "You", noPassword, now, now))
+	mock.ExpectQuery(`FROM user_identities`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("user-1", "you@example.com", "You", noPassword, now, now))
+	mock.ExpectRollback()
+
+	user, err := database.ProvisionUser("https://issuer", "subject", "you@example.com", "You", false)
+	if err != nil {
+		t.Fatalf("ProvisionUser() error = %v", err)
+	}
+	if user.ID != "user-1" {
+		t.Errorf("ProvisionUser() user = %q, want user-1", user.ID)
+	}
+}
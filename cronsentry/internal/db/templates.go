@@ -0,0 +1,111 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+const templateColumns = `id, user_id, channel, event_type, part, body, created_at, updated_at`
+
+func scanTemplate(row rowScanner) (*models.Template, error) {
+	var template models.Template
+	err := row.Scan(
+		&template.ID, &template.UserID, &template.Channel, &template.EventType,
+		&template.Part, &template.Body, &template.CreatedAt, &template.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (d *Database) GetTemplate(id string) (*models.Template, error) {
+	query := `SELECT ` + templateColumns + `
+		FROM templates
+		WHERE id = $1
+	`
+
+	template, err := scanTemplate(d.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error querying template: %w", err)
+	}
+
+	return template, nil
+}
+
+func (d *Database) ListTemplatesByUser(userID string) ([]*models.Template, error) {
+	query := `SELECT ` + templateColumns + `
+		FROM templates
+		WHERE user_id = $1
+		ORDER BY channel, event_type, part
+	`
+	rows, err := d.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []*models.Template
+	for rows.Next() {
+		template, err := scanTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning template row: %w", err)
+		}
+		templates = append(templates, template)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating template rows: %w", err)
+	}
+
+	return templates, nil
+}
+
+// SaveTemplate creates the override of a part, or replaces the existing one
+// for the same channel and event type.
+func (d *Database) SaveTemplate(template *models.Template) error {
+	query := `
+		INSERT INTO templates (id, user_id, channel, event_type, part, body, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (user_id, channel, event_type, part)
+		DO UPDATE SET body = EXCLUDED.body, updated_at = EXCLUDED.updated_at
+		RETURNING id, created_at, updated_at
+	`
+
+	err := d.db.QueryRow(
+		query, uuid.New().String(), template.UserID, template.Channel, template.EventType,
+		template.Part, template.Body, time.Now().UTC(),
+	).Scan(&template.ID, &template.CreatedAt, &template.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("error saving template: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) DeleteTemplate(id string) error {
+	query := `DELETE FROM templates WHERE id = $1`
+	result, err := d.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("error deleting template: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("template not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+var ErrEmailTaken = errors.New("email already registered")
+
+const userColumns = `id, email, name, password_hash, created_at, updated_at`
+
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (d *Database) GetUser(id string) (*models.User, error) {
+	query := `SELECT ` + userColumns + `
+		FROM users
+		WHERE id = $1
+	`
+
+	user, err := scanUser(d.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error querying user: %w", err)
+	}
+
+	return user, nil
+}
+
+// GetUserByEmail looks up a user by email address, ignoring case.
+func (d *Database) GetUserByEmail(email string) (*models.User, error) {
+	query := `SELECT ` + userColumns + `
+		FROM users
+		WHERE email = $1
+	`
+
+	user, err := scanUser(d.db.QueryRow(query, strings.ToLower(email)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error querying user: %w", err)
+	}
+
+	return user, nil
+}
+
+// HasAccounts reports whether any user can sign in, with a password or SSO.
+func (d *Database) HasAccounts() (bool, error) {
+	var exists bool
+	err := d.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM users
+			WHERE password_hash NOT IN ($1, $2) OR id IN (SELECT user_id FROM user_identities)
+		)
+	`, noPassword, lockedPassword).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("error querying users: %w", err)
+	}
+
+	return exists, nil
+}
+
+// CreateUser stores a new user. Password must already be hashed. It returns
+// ErrEmailTaken when another user has the same email address.
+func (d *Database) CreateUser(user *models.User) error {
+	if user.ID == "" {
+		user.ID = uuid.New().String()
+	}
+
+	now := time.Now().UTC()
+	user.Email = strings.ToLower(user.Email)
+	user.CreatedAt = now
+	user.UpdatedAt = now
+
+	query := `INSERT INTO users (` + userColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (email) DO NOTHING
+	`
+	result, err := d.db.Exec(query, user.ID, user.Email, user.Name, user.Password, user.CreatedAt, user.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating user: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrEmailTaken
+	}
+
+	return nil
+}
+
+// CreateSession starts a session for the user and returns its token. Only a
+// hash of the token is stored. Expired sessions of the user are removed.
+func (d *Database) CreateSession(userID string, ttl time.Duration) (string, time.Time, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(ttl)
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE user_id = $1 AND expires_at <= $2`, userID, now); err != nil {
+		return "", time.Time{}, fmt.Errorf("error deleting expired sessions: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO sessions (id, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, hashToken(token), userID, expiresAt, now)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error creating session: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", time.Time{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return token, expiresAt, nil
+}
+
+// GetSessionUserID returns the user of an unexpired session, or an empty
+// string when the token does not belong to one.
+func (d *Database) GetSessionUserID(token string) (string, error) {
+	var userID string
+	err := d.db.QueryRow(`
+		SELECT user_id
+		FROM sessions
+		WHERE id = $1 AND expires_at > $2
+	`, hashToken(token), time.Now().UTC()).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("error querying session: %w", err)
+	}
+
+	return userID, nil
+}
+
+func (d *Database) DeleteSession(token string) error {
+	if _, err := d.db.Exec(`DELETE FROM sessions WHERE id = $1`, hashToken(token)); err != nil {
+		return fmt.Errorf("error deleting session: %w", err)
+	}
+	return nil
+}
+
+// newToken returns a random token that is safe to use in cookies and headers.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is how tokens are stored, so a leaked table cannot be used to
+// sign in. Tokens are random, so a plain SHA-256 is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// APIKey lets scripts use the API on behalf of a user. Only a hash of the key
+// is stored, Key is set once when the key is created.
+type APIKey struct {
+	ID         string     `json:"id" db:"id"`
+	UserID     string     `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix"` // start of the key, to tell keys apart
+	Key        string     `json:"key,omitempty" db:"-"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
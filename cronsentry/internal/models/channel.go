@@ -0,0 +1,30 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ChannelEmail   = "email"
+	ChannelSlack   = "slack"
+	ChannelDiscord = "discord"
+	ChannelTeams   = "teams"
+	ChannelWebhook = "webhook"
+	// ChannelPagerDuty and ChannelOpsgenie open incidents that are resolved
+	// again when the job recovers.
+	ChannelPagerDuty = "pagerduty"
+	ChannelOpsgenie  = "opsgenie"
+)
+
+// Channel is a user configured notification target. Config holds the settings
+// of the channel type, e.g. a webhook URL.
+type Channel struct {
+	ID        string          `json:"id" db:"id"`
+	UserID    string          `json:"user_id" db:"user_id"`
+	Type      string          `json:"type" db:"type"`
+	Name      string          `json:"name" db:"name"`
+	Config    json.RawMessage `json:"config" db:"config"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is a time.Duration that is stored as whole seconds and encoded in
+// JSON as a Go duration string such as "15m". A plain JSON number is read as
+// seconds.
+type Duration time.Duration
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case float64:
+		*d = Duration(time.Duration(v * float64(time.Second)))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", v, err)
+		}
+		*d = Duration(parsed)
+	case nil:
+		*d = 0
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+
+	return nil
+}
+
+func (d Duration) Value() (driver.Value, error) {
+	return int64(time.Duration(d) / time.Second), nil
+}
+
+func (d *Duration) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		*d = Duration(time.Duration(v) * time.Second)
+	case nil:
+		*d = 0
+	default:
+		return fmt.Errorf("cannot scan %T into Duration", src)
+	}
+	return nil
+}
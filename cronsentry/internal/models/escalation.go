@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// EscalationPolicy notifies more channels the longer an alert stays
+// unacknowledged and unresolved.
+type EscalationPolicy struct {
+	ID        string           `json:"id" db:"id"`
+	UserID    string           `json:"user_id" db:"user_id"`
+	Name      string           `json:"name" db:"name"`
+	Steps     []EscalationStep `json:"steps" db:"steps"`
+	CreatedAt time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+// EscalationStep notifies its channels Delay after the alert.
+type EscalationStep struct {
+	Delay      Duration `json:"delay"`
+	ChannelIDs []string `json:"channel_ids"`
+}
+
+// Escalation is the progress of a policy for the current alert of a job.
+type Escalation struct {
+	JobID     string     `json:"job_id" db:"job_id"`
+	PolicyID  string     `json:"policy_id" db:"policy_id"`
+	Step      int        `json:"step" db:"step"`       // next step to notify
+	NextAt    *time.Time `json:"next_at" db:"next_at"` // nil once all steps were notified
+	StartedAt time.Time  `json:"started_at" db:"started_at"`
+	AckedAt   *time.Time `json:"acked_at,omitempty" db:"acked_at"`
+	AckedBy   *string    `json:"acked_by,omitempty" db:"acked_by"`
+}
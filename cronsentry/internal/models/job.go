@@ -1,45 +1,117 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
 type JobStatus string
 
 const (
-	StatusHealthy JobStatus = "healthy"
-	StatusMissing JobStatus = "missing"
-	StatusPaused  JobStatus = "paused"
+	StatusHealthy     JobStatus = "healthy"
+	StatusMissing     JobStatus = "missing"
+	StatusPaused      JobStatus = "paused"
+	StatusFailed      JobStatus = "failed"
+	StatusLongRunning JobStatus = "long_running" // started but not finished within MaxRuntime
+)
+
+type JobKind string
+
+const (
+	KindCron   JobKind = "cron"   // expected according to Schedule
+	KindPeriod JobKind = "period" // expected Period after the last ping
 )
 
 type Job struct {
-	ID          string    `json:"id" db:"id"`
-	Name        string    `json:"name" db:"name"`
-	Description string    `json:"description" db:"description"`
-	Schedule    string    `json:"schedule" db:"schedule"`
-	GraceTime   int       `json:"grace_time" db:"grace_time"` // minutes
-	LastPing    time.Time `json:"last_ping" db:"last_ping"`
-	NextExpect  time.Time `json:"next_expect" db:"next_expect"`
-	Status      JobStatus `json:"status" db:"status"`
-	UserID      string    `json:"user_id" db:"user_id"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID          string   `json:"id" db:"id"`
+	Name        string   `json:"name" db:"name"`
+	Slug        string   `json:"slug" db:"slug"` // unique per user, names the job in ping URLs
+	Description string   `json:"description" db:"description"`
+	Kind        JobKind  `json:"kind" db:"kind"`
+	Schedule    string   `json:"schedule" db:"schedule"`
+	Period      Duration `json:"period" db:"period"`
+	Timezone    string   `json:"timezone" db:"timezone"` // IANA name the schedule is evaluated in
+	GracePeriod Duration `json:"grace_period" db:"grace_period"`
+	MaxRuntime  Duration `json:"max_runtime" db:"max_runtime"` // 0 disables the long-running check
+	// EscalationPolicyID replaces notifying all channels at once with the
+	// steps of the policy.
+	EscalationPolicyID *string `json:"escalation_policy_id" db:"escalation_policy_id"`
+	// ReminderInterval repeats the alert while the job stays missing or
+	// failed, at most MaxReminders times. 0 disables reminders.
+	ReminderInterval Duration  `json:"reminder_interval" db:"reminder_interval"`
+	MaxReminders     int       `json:"max_reminders" db:"max_reminders"`
+	LastPing         time.Time `json:"last_ping" db:"last_ping"`
+	NextExpect       time.Time `json:"next_expect" db:"next_expect"`
+	Status           JobStatus `json:"status" db:"status"`
+	UserID           string    `json:"user_id" db:"user_id"`
+	ProjectID        *string   `json:"project_id" db:"project_id"` // pinged with the ping key of the project
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type JobEventType string
 
 const (
-	TypePing     JobEventType = "ping"
-	TypeMiss     JobEventType = "miss"
-	TypeRecovery JobEventType = "recovery"
+	TypePing        JobEventType = "ping"
+	TypeMiss        JobEventType = "miss"
+	TypeRecovery    JobEventType = "recovery"
+	TypeStart       JobEventType = "start"
+	TypeFail        JobEventType = "fail"
+	TypeLongRunning JobEventType = "long_running"
+	TypeAck         JobEventType = "ack"      // alert acknowledged, stops its escalation
+	TypeReminder    JobEventType = "reminder" // repeated alert while the job is still down
+)
+
+type PingKind string
+
+const (
+	PingStart   PingKind = "start"
+	PingSuccess PingKind = "success"
+	PingFail    PingKind = "fail"
+)
+
+// Ping is a signal received from a monitored job. Everything except Kind is
+// stored as the event data.
+type Ping struct {
+	Kind     PingKind `json:"-"`
+	ExitCode *int     `json:"exit_code,omitempty"`
+	Body     string   `json:"body,omitempty"` // truncated request body, e.g. the tail of the job output
+}
+
+type JobRunStatus string
+
+const (
+	RunRunning   JobRunStatus = "running"
+	RunSuccess   JobRunStatus = "success"
+	RunFailed    JobRunStatus = "failed"
+	RunAbandoned JobRunStatus = "abandoned" // started again before it finished
 )
 
+type JobRun struct {
+	ID         string       `json:"id" db:"id"`
+	JobID      string       `json:"job_id" db:"job_id"`
+	Status     JobRunStatus `json:"status" db:"status"`
+	StartedAt  *time.Time   `json:"started_at" db:"started_at"`   // nil when no start ping was received
+	FinishedAt *time.Time   `json:"finished_at" db:"finished_at"` // nil while the run is in progress
+	DurationMs *int64       `json:"duration_ms" db:"duration_ms"`
+}
+
 type JobEvent struct {
-	ID        string       `json:"id" db:"id"`
-	JobID     string       `json:"job_id" db:"job_id"`
-	Type      JobEventType `json:"type" db:"type"`
-	Data      string       `json:"data" db:"data"` // additional data as JSON string
-	CreatedAt time.Time    `json:"created_at" db:"created_at"`
+	ID        string          `json:"id" db:"id"`
+	JobID     string          `json:"job_id" db:"job_id"`
+	Type      JobEventType    `json:"type" db:"type"`
+	Data      json.RawMessage `json:"data" db:"data"` // additional data as JSON
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+type EventFilter struct {
+	UserID string
+	JobID  string // empty for events across all jobs of the user
+	Types  []JobEventType
+	Since  *time.Time
+	Until  *time.Time
+	Cursor string // opaque, taken from the previous page
+	Limit  int
 }
 
 type User struct {
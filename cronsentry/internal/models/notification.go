@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+type NotificationStatus string
+
+const (
+	NotificationPending NotificationStatus = "pending"
+	// NotificationSending marks notifications a processor claimed and is
+	// sending until their lease expires.
+	NotificationSending NotificationStatus = "sending"
+	NotificationSent    NotificationStatus = "sent"
+	// NotificationDead marks notifications that failed too often and are no
+	// longer retried until they are re-driven.
+	NotificationDead NotificationStatus = "dead"
+)
+
+// Notification is a queued alert for one channel, together with its delivery
+// state.
+type Notification struct {
+	ID            string             `json:"id" db:"id"`
+	UserID        string             `json:"user_id" db:"user_id"`
+	JobID         string             `json:"job_id" db:"job_id"`
+	EventID       *string            `json:"event_id,omitempty" db:"event_id"`
+	ChannelID     *string            `json:"channel_id,omitempty" db:"channel_id"`
+	Type          string             `json:"type" db:"type"`
+	Status        NotificationStatus `json:"status" db:"status"`
+	Attempts      int                `json:"attempts" db:"attempts"`
+	NextAttemptAt *time.Time         `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	LastError     string             `json:"last_error,omitempty" db:"last_error"`
+	SentAt        *time.Time         `json:"sent_at,omitempty" db:"sent_at"`
+	CreatedAt     time.Time          `json:"created_at" db:"created_at"`
+}
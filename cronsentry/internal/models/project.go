@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Project groups jobs. Its ping key lets crontabs ping the jobs of the
+// project by slug, without API credentials. Only a hash of the key is stored,
+// PingKey is set once when the key is created.
+type Project struct {
+	ID            string    `json:"id" db:"id"`
+	UserID        string    `json:"user_id" db:"user_id"`
+	Name          string    `json:"name" db:"name"`
+	PingKeyPrefix *string   `json:"ping_key_prefix" db:"ping_key_prefix"` // start of the key, nil without a key
+	PingKey       string    `json:"ping_key,omitempty" db:"-"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
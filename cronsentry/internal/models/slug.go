@@ -0,0 +1,42 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+const maxSlugLength = 100
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// ValidSlug reports whether s can be used as a job slug: lowercase letters
+// and digits, separated by single dashes.
+func ValidSlug(s string) bool {
+	return len(s) <= maxSlugLength && slugPattern.MatchString(s)
+}
+
+// Slugify turns a job name into a slug, e.g. "Nightly DB Backup" into
+// "nightly-db-backup". Names without letters or digits become "job".
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= maxSlugLength-10 { // leaves room for a suffix
+			break
+		}
+	}
+
+	if b.Len() == 0 {
+		return "job"
+	}
+	return b.String()
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+type TeamRole string
+
+const (
+	RoleMember TeamRole = "member"
+	RoleAdmin  TeamRole = "admin"
+)
+
+var roleRanks = map[TeamRole]int{
+	RoleMember: 1,
+	RoleAdmin:  2,
+}
+
+func (r TeamRole) Valid() bool {
+	return roleRanks[r] > 0
+}
+
+// Outranks reports whether r grants more than other.
+func (r TeamRole) Outranks(other TeamRole) bool {
+	return roleRanks[r] > roleRanks[other]
+}
+
+type Team struct {
+	ID        string    `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// TeamMembership is the role of a user in a team. Memberships are granted by
+// the groups of the user at the SSO provider, and updated on every login.
+type TeamMembership struct {
+	TeamID   string   `json:"team_id" db:"team_id"`
+	TeamName string   `json:"team_name" db:"name"`
+	UserID   string   `json:"user_id" db:"user_id"`
+	Role     TeamRole `json:"role" db:"role"`
+}
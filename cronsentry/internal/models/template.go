@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Template is a user override of one part of the notification content for a
+// channel type and event type.
+type Template struct {
+	ID        string       `json:"id" db:"id"`
+	UserID    string       `json:"user_id" db:"user_id"`
+	Channel   string       `json:"channel" db:"channel"`
+	EventType JobEventType `json:"event_type" db:"event_type"`
+	Part      string       `json:"part" db:"part"`
+	Body      string       `json:"body" db:"body"`
+	CreatedAt time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at" db:"updated_at"`
+}
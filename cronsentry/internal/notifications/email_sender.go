@@ -1,5 +1,70 @@
 package notifications
 
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+)
+
+// EmailSender delivers a message with a plain text and an HTML version of
+// the same content.
 type EmailSender interface {
-	SendEmail(email, subject, body string) error
+	SendEmail(email, subject, textBody, htmlBody string) error
+}
+
+type emailConfig struct {
+	Address string `json:"address"` // defaults to the address of the user
+}
+
+// EmailNotifier sends notifications through an EmailSender.
+type EmailNotifier struct {
+	sender EmailSender
+}
+
+func NewEmailNotifier(sender EmailSender) *EmailNotifier {
+	return &EmailNotifier{sender: sender}
+}
+
+func (en *EmailNotifier) Validate(config json.RawMessage) error {
+	cfg, err := parseEmailConfig(config)
+	if err != nil {
+		return err
+	}
+	if cfg.Address != "" {
+		if _, err := mail.ParseAddress(cfg.Address); err != nil {
+			return errors.New("invalid email address")
+		}
+	}
+	return nil
+}
+
+func (en *EmailNotifier) Notify(ctx context.Context, notification Notification) error {
+	cfg, err := parseEmailConfig(notification.Config)
+	if err != nil {
+		return err
+	}
+
+	to := cfg.Address
+	if to == "" {
+		to = notification.UserEmail
+	}
+
+	if err := en.sender.SendEmail(to, notification.Content.Title, notification.Content.Text, notification.Content.HTML); err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+
+	return nil
+}
+
+func parseEmailConfig(config json.RawMessage) (emailConfig, error) {
+	var cfg emailConfig
+	if len(config) == 0 {
+		return cfg, nil
+	}
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid email channel config: %w", err)
+	}
+	return cfg, nil
 }
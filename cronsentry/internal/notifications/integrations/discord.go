@@ -0,0 +1,87 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zigamedved/cronsentry/internal/notifications"
+)
+
+var discordColors = map[severity]int{
+	severityCritical: 0xdc2626,
+	severityWarning:  0xf59e0b,
+	severityGood:     0x16a34a,
+}
+
+type discordConfig struct {
+	WebhookURL string `json:"webhook_url"`
+}
+
+// DiscordNotifier posts notifications to a Discord webhook as an embed.
+type DiscordNotifier struct {
+	client       *http.Client
+	dashboardURL string
+}
+
+func NewDiscordNotifier(client *http.Client, dashboardURL string) *DiscordNotifier {
+	return &DiscordNotifier{
+		client:       client,
+		dashboardURL: dashboardURL,
+	}
+}
+
+func (dn *DiscordNotifier) Validate(config json.RawMessage) error {
+	var cfg discordConfig
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return fmt.Errorf("invalid discord channel config: %w", err)
+	}
+	return validateURL(cfg.WebhookURL)
+}
+
+func (dn *DiscordNotifier) Notify(ctx context.Context, notification notifications.Notification) error {
+	var cfg discordConfig
+	if err := json.Unmarshal(notification.Config, &cfg); err != nil {
+		return fmt.Errorf("invalid discord channel config: %w", err)
+	}
+
+	return postJSON(ctx, dn.client, cfg.WebhookURL, nil, dn.message(notification))
+}
+
+func (dn *DiscordNotifier) message(n notifications.Notification) map[string]any {
+	fields := []map[string]any{
+		discordField("Job", n.JobName, true),
+		discordField("Schedule", "`"+n.ScheduleText()+"`", true),
+		discordField("Last ping", formatTime(n.LastPing), false),
+		discordField("Expected", formatTime(n.NextExpect), false),
+	}
+	if n.Output != "" {
+		fields = append(fields, discordField("Output", "```"+tail(n.Output, 1024-6)+"```", false))
+	}
+
+	embed := map[string]any{
+		"title":       truncate(n.Content.Title, 256),
+		"description": truncate(n.Content.Message, 4096),
+		"color":       discordColors[severityOf(n)],
+		"fields":      fields,
+		"timestamp":   n.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if dn.dashboardURL != "" {
+		embed["url"] = dn.dashboardURL
+	}
+
+	return map[string]any{
+		"username": "CronSentry",
+		"embeds":   []map[string]any{embed},
+	}
+}
+
+func discordField(name, value string, inline bool) map[string]any {
+	return map[string]any{
+		"name":   name,
+		"value":  truncate(value, 1024),
+		"inline": inline,
+	}
+}
@@ -0,0 +1,59 @@
+package integrations
+
+import (
+	"time"
+	"unicode/utf8"
+
+	"github.com/zigamedved/cronsentry/internal/models"
+	"github.com/zigamedved/cronsentry/internal/notifications"
+)
+
+// formatTime renders times in notifications, or "never" for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.UTC().Format("2006-01-02 15:04:05 MST")
+}
+
+// truncate shortens s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// tail keeps the last n bytes of s, which is where job output usually has
+// the interesting part.
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	start := len(s) - n
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+	return s[start:]
+}
+
+type severity int
+
+const (
+	severityCritical severity = iota
+	severityWarning
+	severityGood
+)
+
+func severityOf(n notifications.Notification) severity {
+	switch n.EventType {
+	case models.TypeRecovery:
+		return severityGood
+	case models.TypeLongRunning:
+		return severityWarning
+	}
+	return severityCritical
+}
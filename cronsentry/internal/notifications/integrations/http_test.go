@@ -0,0 +1,55 @@
+package integrations
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDenyNonPublic(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.215.14:443", true},
+		{"[2606:2800:21f:cb07:6820:80da:af6b:8b2c]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"[fd00::1]:80", false},
+		{"100.64.0.1:80", false},
+		{"0.0.0.0:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"[::ffff:169.254.169.254]:80", false},
+		{"[64:ff9b::a9fe:a9fe]:80", false},
+	}
+
+	for _, tt := range tests {
+		err := denyNonPublic("tcp", tt.address, nil)
+		if allowed := err == nil; allowed != tt.allowed {
+			t.Errorf("denyNonPublic(%q) = %v, want allowed %v", tt.address, err, tt.allowed)
+		}
+	}
+}
+
+func TestWebhookClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	resp, err := NewWebhookClient(time.Second, false).Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("request to loopback address succeeded")
+	}
+
+	resp, err = NewWebhookClient(time.Second, true).Get(server.URL)
+	if err != nil {
+		t.Fatalf("request with private addresses allowed failed: %v", err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,129 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/zigamedved/cronsentry/internal/models"
+	"github.com/zigamedved/cronsentry/internal/notifications"
+)
+
+const (
+	DefaultOpsgenieURL   = "https://api.opsgenie.com"
+	DefaultOpsgenieEUURL = "https://api.eu.opsgenie.com"
+)
+
+// Opsgenie accounts live in one of these regions.
+const (
+	opsgenieRegionUS = "us"
+	opsgenieRegionEU = "eu"
+)
+
+type opsgenieConfig struct {
+	APIKey   string `json:"api_key"`
+	Region   string `json:"region"`   // us or eu, defaults to us
+	Priority string `json:"priority"` // P1 to P5, defaults to P1
+}
+
+// OpsgenieNotifier creates Opsgenie alerts and closes them when the job
+// recovers. The job ID is the alert alias, so Opsgenie deduplicates repeated
+// alerts for the same job. Alerts go to the base URL the server is configured
+// with for the region of the channel, channels cannot send them elsewhere.
+type OpsgenieNotifier struct {
+	client       *http.Client
+	baseURLs     map[string]string // by region
+	dashboardURL string
+}
+
+func NewOpsgenieNotifier(client *http.Client, usURL, euURL, dashboardURL string) *OpsgenieNotifier {
+	return &OpsgenieNotifier{
+		client: client,
+		baseURLs: map[string]string{
+			opsgenieRegionUS: usURL,
+			opsgenieRegionEU: euURL,
+		},
+		dashboardURL: dashboardURL,
+	}
+}
+
+func (on *OpsgenieNotifier) Validate(config json.RawMessage) error {
+	cfg, err := parseOpsgenieConfig(config)
+	if err != nil {
+		return err
+	}
+	if cfg.APIKey == "" {
+		return errors.New("api_key is required")
+	}
+	switch cfg.Priority {
+	case "", "P1", "P2", "P3", "P4", "P5":
+	default:
+		return fmt.Errorf("invalid priority %q", cfg.Priority)
+	}
+	switch cfg.Region {
+	case "", opsgenieRegionUS, opsgenieRegionEU:
+	default:
+		return fmt.Errorf("invalid region %q, expected us or eu", cfg.Region)
+	}
+	return nil
+}
+
+func (on *OpsgenieNotifier) Notify(ctx context.Context, notification notifications.Notification) error {
+	cfg, err := parseOpsgenieConfig(notification.Config)
+	if err != nil {
+		return err
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = opsgenieRegionUS
+	}
+	baseURL, ok := on.baseURLs[region]
+	if !ok {
+		return fmt.Errorf("invalid region %q", cfg.Region)
+	}
+	baseURL = strings.TrimRight(baseURL, "/")
+	headers := map[string]string{"Authorization": "GenieKey " + cfg.APIKey}
+
+	if notification.EventType == models.TypeRecovery {
+		closeURL := fmt.Sprintf("%s/v2/alerts/%s/close?identifierType=alias", baseURL, url.PathEscape(notification.JobID))
+		return postJSON(ctx, on.client, closeURL, headers, map[string]string{
+			"source": "CronSentry",
+			"note":   notification.Content.Message,
+		})
+	}
+
+	priority := cfg.Priority
+	if priority == "" {
+		priority = "P1"
+	}
+
+	alert := map[string]any{
+		"message":     truncate(notification.Content.Title, 130),
+		"alias":       notification.JobID,
+		"description": truncate(notification.Content.Message+"\n\n"+notification.Output, 15000),
+		"priority":    priority,
+		"source":      "CronSentry",
+		"entity":      notification.JobName,
+		"details": map[string]string{
+			"schedule":    notification.ScheduleText(),
+			"last_ping":   formatTime(notification.LastPing),
+			"next_expect": formatTime(notification.NextExpect),
+			"dashboard":   on.dashboardURL,
+		},
+	}
+
+	return postJSON(ctx, on.client, baseURL+"/v2/alerts", headers, alert)
+}
+
+func parseOpsgenieConfig(config json.RawMessage) (opsgenieConfig, error) {
+	var cfg opsgenieConfig
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid opsgenie channel config: %w", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,84 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/zigamedved/cronsentry/internal/models"
+	"github.com/zigamedved/cronsentry/internal/notifications"
+	"github.com/zigamedved/cronsentry/internal/templates"
+)
+
+func TestOpsgenieCreateAndClose(t *testing.T) {
+	us, usRequests := captureServer(t)
+	eu, euRequests := captureServer(t)
+	notifier := NewOpsgenieNotifier(us.Client(), us.URL, eu.URL, "")
+
+	tests := []struct {
+		region   string
+		requests *[]capturedRequest
+	}{
+		{"", usRequests},
+		{"eu", euRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run("region "+tt.region, func(t *testing.T) {
+			config, _ := json.Marshal(opsgenieConfig{APIKey: "genie-key", Region: tt.region})
+			for _, eventType := range []models.JobEventType{models.TypeFail, models.TypeRecovery} {
+				notification := notifications.Notification{
+					EventType: eventType,
+					JobID:     "job/1",
+					JobName:   "backup",
+					Config:    config,
+					Content:   templates.Content{Title: "Job backup failed", Message: "Job backup failed"},
+				}
+				if err := notifier.Notify(context.Background(), notification); err != nil {
+					t.Fatalf("Notify(%s) error = %v", eventType, err)
+				}
+			}
+
+			requests := *tt.requests
+			if len(requests) != 2 {
+				t.Fatalf("got %d requests, want 2", len(requests))
+			}
+
+			for _, request := range requests {
+				if got := request.header.Get("Authorization"); got != "GenieKey genie-key" {
+					t.Errorf("Authorization = %q, want GenieKey genie-key", got)
+				}
+			}
+
+			create := requests[0]
+			if create.path != "/v2/alerts" {
+				t.Errorf("create path = %s, want /v2/alerts", create.path)
+			}
+			var alert struct {
+				Alias    string `json:"alias"`
+				Priority string `json:"priority"`
+			}
+			if err := json.Unmarshal(create.body, &alert); err != nil {
+				t.Fatal(err)
+			}
+			if alert.Alias != "job/1" || alert.Priority != "P1" {
+				t.Errorf("alert alias = %q priority = %q, want job/1 and P1", alert.Alias, alert.Priority)
+			}
+
+			// the alert is closed by the alias it was created with
+			closeRequest := requests[1]
+			if closeRequest.path != "/v2/alerts/job%2F1/close" || closeRequest.query != "identifierType=alias" {
+				t.Errorf("close URL = %s?%s, want /v2/alerts/job%%2F1/close?identifierType=alias", closeRequest.path, closeRequest.query)
+			}
+		})
+	}
+}
+
+func TestOpsgenieRejectsUnknownRegion(t *testing.T) {
+	notifier := NewOpsgenieNotifier(http.DefaultClient, DefaultOpsgenieURL, DefaultOpsgenieEUURL, "")
+	config, _ := json.Marshal(opsgenieConfig{APIKey: "genie-key", Region: "apac"})
+	if err := notifier.Validate(config); err == nil {
+		t.Error("Validate() accepted region apac")
+	}
+}
@@ -0,0 +1,104 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zigamedved/cronsentry/internal/models"
+	"github.com/zigamedved/cronsentry/internal/notifications"
+)
+
+const DefaultPagerDutyURL = "https://events.pagerduty.com"
+
+type pagerDutyConfig struct {
+	RoutingKey string `json:"routing_key"`
+}
+
+// PagerDutyNotifier triggers PagerDuty incidents through the Events API v2
+// and resolves them when the job recovers. The job ID is the dedup key, so
+// repeated alerts for the same job update one incident. Events go to the base
+// URL the server is configured with, channels cannot send them elsewhere.
+type PagerDutyNotifier struct {
+	client       *http.Client
+	baseURL      string
+	dashboardURL string
+}
+
+func NewPagerDutyNotifier(client *http.Client, baseURL, dashboardURL string) *PagerDutyNotifier {
+	return &PagerDutyNotifier{
+		client:       client,
+		baseURL:      baseURL,
+		dashboardURL: dashboardURL,
+	}
+}
+
+func (pn *PagerDutyNotifier) Validate(config json.RawMessage) error {
+	cfg, err := parsePagerDutyConfig(config)
+	if err != nil {
+		return err
+	}
+	if cfg.RoutingKey == "" {
+		return errors.New("routing_key is required")
+	}
+	return nil
+}
+
+func (pn *PagerDutyNotifier) Notify(ctx context.Context, notification notifications.Notification) error {
+	cfg, err := parsePagerDutyConfig(notification.Config)
+	if err != nil {
+		return err
+	}
+
+	return postJSON(ctx, pn.client, strings.TrimRight(pn.baseURL, "/")+"/v2/enqueue", nil, pn.event(cfg, notification))
+}
+
+func (pn *PagerDutyNotifier) event(cfg pagerDutyConfig, n notifications.Notification) map[string]any {
+	event := map[string]any{
+		"routing_key": cfg.RoutingKey,
+		"dedup_key":   n.JobID,
+	}
+
+	if n.EventType == models.TypeRecovery {
+		event["event_action"] = "resolve"
+		return event
+	}
+
+	severity := "critical"
+	if severityOf(n) == severityWarning {
+		severity = "warning"
+	}
+
+	event["event_action"] = "trigger"
+	event["payload"] = map[string]any{
+		"summary":   truncate(n.Content.Message, 1024),
+		"source":    "cronsentry",
+		"severity":  severity,
+		"timestamp": n.CreatedAt.UTC().Format(time.RFC3339),
+		"component": n.JobName,
+		"custom_details": map[string]any{
+			"job_id":      n.JobID,
+			"schedule":    n.ScheduleText(),
+			"last_ping":   formatTime(n.LastPing),
+			"next_expect": formatTime(n.NextExpect),
+			"output":      n.Output,
+		},
+	}
+	if pn.dashboardURL != "" {
+		event["links"] = []map[string]string{{"href": pn.dashboardURL, "text": "CronSentry dashboard"}}
+	}
+
+	return event
+}
+
+func parsePagerDutyConfig(config json.RawMessage) (pagerDutyConfig, error) {
+	var cfg pagerDutyConfig
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid pagerduty channel config: %w", err)
+	}
+	return cfg, nil
+}
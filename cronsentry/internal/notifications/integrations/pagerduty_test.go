@@ -0,0 +1,61 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/zigamedved/cronsentry/internal/models"
+	"github.com/zigamedved/cronsentry/internal/notifications"
+	"github.com/zigamedved/cronsentry/internal/templates"
+)
+
+func TestPagerDutyTriggerAndResolve(t *testing.T) {
+	server, requests := captureServer(t)
+	notifier := NewPagerDutyNotifier(server.Client(), server.URL, "")
+	config, _ := json.Marshal(pagerDutyConfig{RoutingKey: "routing-key"})
+
+	for _, eventType := range []models.JobEventType{models.TypeMiss, models.TypeFail, models.TypeRecovery} {
+		notification := notifications.Notification{
+			EventType: eventType,
+			JobID:     "job-1",
+			JobName:   "backup",
+			Config:    config,
+			Content:   templates.Content{Title: "Job backup", Message: "Job backup is down"},
+		}
+		if err := notifier.Notify(context.Background(), notification); err != nil {
+			t.Fatalf("Notify(%s) error = %v", eventType, err)
+		}
+	}
+
+	wantActions := []string{"trigger", "trigger", "resolve"}
+	if len(*requests) != len(wantActions) {
+		t.Fatalf("got %d requests, want %d", len(*requests), len(wantActions))
+	}
+
+	for i, request := range *requests {
+		if request.path != "/v2/enqueue" {
+			t.Errorf("request %d path = %s, want /v2/enqueue", i, request.path)
+		}
+
+		var event struct {
+			RoutingKey  string `json:"routing_key"`
+			DedupKey    string `json:"dedup_key"`
+			EventAction string `json:"event_action"`
+		}
+		if err := json.Unmarshal(request.body, &event); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+
+		if event.RoutingKey != "routing-key" {
+			t.Errorf("request %d routing_key = %q, want routing-key", i, event.RoutingKey)
+		}
+		// the same dedup key resolves the incident the alerts opened
+		if event.DedupKey != "job-1" {
+			t.Errorf("request %d dedup_key = %q, want job-1", i, event.DedupKey)
+		}
+		if event.EventAction != wantActions[i] {
+			t.Errorf("request %d event_action = %q, want %q", i, event.EventAction, wantActions[i])
+		}
+	}
+}
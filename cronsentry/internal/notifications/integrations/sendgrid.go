@@ -1,6 +1,7 @@
 package integrations
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/sendgrid/sendgrid-go"
@@ -21,19 +22,20 @@ func NewSendgridSendClient(apiKey string, logger *log.Logger, enabled bool) Send
 	}
 }
 
-func (sc SendgridClient) SendEmail(to, subject, body string) error {
+func (sc SendgridClient) SendEmail(to, subject, textBody, htmlBody string) error {
 	if !sc.enabled {
 		sc.logger.Printf("Email would be sent to %s: %s", to, subject)
 		return nil
 	}
 
 	from := mail.NewEmail("CronSentry", "cronsentry@example.com")
-	message := mail.NewSingleEmail(from, subject, &mail.Email{Name: to, Address: to}, body, "") // fix last arg
+	message := mail.NewSingleEmail(from, subject, &mail.Email{Name: to, Address: to}, textBody, htmlBody)
 	response, err := sc.Send(message)
 	if err != nil {
-		sc.logger.Println("Error sending email", err)
-	} else {
-		sc.logger.Printf("Status code %d, headers: %v", response.StatusCode, response.Headers)
+		return fmt.Errorf("error sending email through sendgrid: %w", err)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("sendgrid responded with status %d: %s", response.StatusCode, truncate(response.Body, 512))
 	}
 
 	return nil
@@ -0,0 +1,105 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/zigamedved/cronsentry/internal/notifications"
+)
+
+// slackTextLimit is the maximum length of a Block Kit text object.
+const slackTextLimit = 3000
+
+// slackEscaper escapes the characters Slack treats as control characters in
+// mrkdwn, so that job names and output cannot mention @channel or form links.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+type slackConfig struct {
+	WebhookURL string `json:"webhook_url"`
+}
+
+// SlackNotifier posts notifications to a Slack incoming webhook using Block
+// Kit messages.
+type SlackNotifier struct {
+	client       *http.Client
+	dashboardURL string
+}
+
+func NewSlackNotifier(client *http.Client, dashboardURL string) *SlackNotifier {
+	return &SlackNotifier{
+		client:       client,
+		dashboardURL: dashboardURL,
+	}
+}
+
+func (sn *SlackNotifier) Validate(config json.RawMessage) error {
+	var cfg slackConfig
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return fmt.Errorf("invalid slack channel config: %w", err)
+	}
+	return validateURL(cfg.WebhookURL)
+}
+
+func (sn *SlackNotifier) Notify(ctx context.Context, notification notifications.Notification) error {
+	var cfg slackConfig
+	if err := json.Unmarshal(notification.Config, &cfg); err != nil {
+		return fmt.Errorf("invalid slack channel config: %w", err)
+	}
+
+	return postJSON(ctx, sn.client, cfg.WebhookURL, nil, sn.message(notification))
+}
+
+func (sn *SlackNotifier) message(n notifications.Notification) map[string]any {
+	blocks := []map[string]any{
+		{
+			"type": "header",
+			"text": slackText("plain_text", truncate(n.Content.Title, 150)),
+		},
+		{
+			"type": "section",
+			"text": slackText("mrkdwn", slackEscaper.Replace(n.Content.Message)),
+		},
+		{
+			"type": "section",
+			"fields": []map[string]any{
+				slackText("mrkdwn", "*Job*\n"+slackEscaper.Replace(n.JobName)),
+				slackText("mrkdwn", "*Schedule*\n`"+slackEscaper.Replace(n.ScheduleText())+"`"),
+				slackText("mrkdwn", "*Last ping*\n"+formatTime(n.LastPing)),
+				slackText("mrkdwn", "*Expected*\n"+formatTime(n.NextExpect)),
+			},
+		},
+	}
+
+	if n.Output != "" {
+		blocks = append(blocks, map[string]any{
+			"type": "section",
+			"text": slackText("mrkdwn", "```"+tail(slackEscaper.Replace(n.Output), slackTextLimit-6)+"```"),
+		})
+	}
+
+	if sn.dashboardURL != "" {
+		blocks = append(blocks, map[string]any{
+			"type": "actions",
+			"elements": []map[string]any{{
+				"type": "button",
+				"text": slackText("plain_text", "Open dashboard"),
+				"url":  sn.dashboardURL,
+			}},
+		})
+	}
+
+	return map[string]any{
+		"text":   slackEscaper.Replace(n.Content.Title), // fallback for notifications and clients without blocks
+		"blocks": blocks,
+	}
+}
+
+func slackText(textType, text string) map[string]any {
+	return map[string]any{
+		"type": textType,
+		"text": truncate(text, slackTextLimit),
+	}
+}
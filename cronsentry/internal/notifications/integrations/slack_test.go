@@ -0,0 +1,81 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/zigamedved/cronsentry/internal/notifications"
+	"github.com/zigamedved/cronsentry/internal/templates"
+)
+
+type slackTestText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackTestMessage struct {
+	Text   string `json:"text"`
+	Blocks []struct {
+		Type     string          `json:"type"`
+		Text     slackTestText   `json:"text"`
+		Fields   []slackTestText `json:"fields"`
+		Elements []struct {
+			URL string `json:"url"`
+		} `json:"elements"`
+	} `json:"blocks"`
+}
+
+func TestSlackMessage(t *testing.T) {
+	server, requests := captureServer(t)
+
+	config, _ := json.Marshal(slackConfig{WebhookURL: server.URL})
+	notification := notifications.Notification{
+		JobName:  "backup <!channel>",
+		Schedule: "0 3 * * *",
+		Output:   "copied 3 files\nexit code > 0 & done",
+		Config:   config,
+		Content: templates.Content{
+			Title:   "Job backup <!channel> failed",
+			Message: "Job 'backup <!channel>' reported a failed run",
+		},
+	}
+
+	err := NewSlackNotifier(server.Client(), "https://cron.example.com").Notify(context.Background(), notification)
+	if err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(*requests))
+	}
+
+	var message slackTestMessage
+	if err := json.Unmarshal((*requests)[0].body, &message); err != nil {
+		t.Fatalf("invalid message: %v", err)
+	}
+	if len(message.Blocks) != 5 {
+		t.Fatalf("got %d blocks, want header, message, fields, output and actions", len(message.Blocks))
+	}
+
+	header, text, fields, output, actions := message.Blocks[0], message.Blocks[1], message.Blocks[2], message.Blocks[3], message.Blocks[4]
+
+	if message.Text != "Job backup &lt;!channel&gt; failed" {
+		t.Errorf("fallback text = %q", message.Text)
+	}
+	// plain text is not parsed for mentions and stays as it is
+	if header.Text != (slackTestText{Type: "plain_text", Text: "Job backup <!channel> failed"}) {
+		t.Errorf("header = %+v", header.Text)
+	}
+	if text.Text.Text != "Job 'backup &lt;!channel&gt;' reported a failed run" {
+		t.Errorf("message text = %q", text.Text.Text)
+	}
+	if len(fields.Fields) != 4 || fields.Fields[0].Text != "*Job*\nbackup &lt;!channel&gt;" {
+		t.Errorf("fields = %+v", fields.Fields)
+	}
+	if output.Text.Text != "```copied 3 files\nexit code &gt; 0 &amp; done```" {
+		t.Errorf("output = %q", output.Text.Text)
+	}
+	if len(actions.Elements) != 1 || actions.Elements[0].URL != "https://cron.example.com" {
+		t.Errorf("actions = %+v", actions.Elements)
+	}
+}
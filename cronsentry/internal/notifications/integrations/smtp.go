@@ -0,0 +1,203 @@
+package integrations
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTP connection security modes.
+const (
+	SMTPStartTLS = "starttls" // upgrade a plain connection, usually port 587
+	SMTPTLS      = "tls"      // implicit TLS, usually port 465
+	SMTPNone     = "none"     // plain connection, only for local relays
+)
+
+const smtpTimeout = 30 * time.Second
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	Security string // one of SMTPStartTLS, SMTPTLS or SMTPNone
+}
+
+// SMTPClient sends email through an SMTP relay, as a multipart message with
+// a plain text and an HTML part.
+type SMTPClient struct {
+	config SMTPConfig
+	from   *mail.Address
+}
+
+func NewSMTPClient(config SMTPConfig) (*SMTPClient, error) {
+	if config.Host == "" {
+		return nil, fmt.Errorf("smtp host is required")
+	}
+	if config.Port == 0 {
+		config.Port = 587
+	}
+	if config.Security == "" {
+		config.Security = SMTPStartTLS
+		if config.Port == 465 {
+			config.Security = SMTPTLS
+		}
+	}
+	switch config.Security {
+	case SMTPStartTLS, SMTPTLS, SMTPNone:
+	default:
+		return nil, fmt.Errorf("invalid smtp security mode %q", config.Security)
+	}
+
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtp from address: %w", err)
+	}
+
+	return &SMTPClient{config: config, from: from}, nil
+}
+
+func (sc *SMTPClient) SendEmail(to, subject, textBody, htmlBody string) error {
+	recipient, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	message, err := sc.buildMessage(recipient, subject, textBody, htmlBody)
+	if err != nil {
+		return err
+	}
+
+	client, err := sc.dial()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if sc.config.Username != "" {
+		auth := smtp.PlainAuth("", sc.config.Username, sc.config.Password, sc.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("smtp authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(sc.from.Address); err != nil {
+		return fmt.Errorf("smtp MAIL FROM failed: %w", err)
+	}
+	if err := client.Rcpt(recipient.Address); err != nil {
+		return fmt.Errorf("smtp RCPT TO failed: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA failed: %w", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		w.Close()
+		return fmt.Errorf("error writing message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp server rejected message: %w", err)
+	}
+
+	return client.Quit()
+}
+
+func (sc *SMTPClient) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(sc.config.Host, strconv.Itoa(sc.config.Port))
+	tlsConfig := &tls.Config{ServerName: sc.config.Host}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+
+	var conn net.Conn
+	var err error
+	if sc.config.Security == SMTPTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to smtp server: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := smtp.NewClient(conn, sc.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error starting smtp session: %w", err)
+	}
+
+	if sc.config.Security == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp STARTTLS failed: %w", err)
+		}
+	}
+
+	return client, nil
+}
+
+func (sc *SMTPClient) buildMessage(to *mail.Address, subject, textBody, htmlBody string) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", sc.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: %s\r\n", sc.messageID())
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", textBody},
+		{"text/html; charset=utf-8", htmlBody},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error building message: %w", err)
+		}
+		qw := quotedprintable.NewWriter(pw)
+		if _, err := qw.Write([]byte(part.body)); err != nil {
+			return nil, fmt.Errorf("error building message: %w", err)
+		}
+		if err := qw.Close(); err != nil {
+			return nil, fmt.Errorf("error building message: %w", err)
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("error building message: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (sc *SMTPClient) messageID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	domain := sc.config.Host
+	if at := strings.LastIndex(sc.from.Address, "@"); at >= 0 {
+		domain = sc.from.Address[at+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain)
+}
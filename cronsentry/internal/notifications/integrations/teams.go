@@ -0,0 +1,113 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/zigamedved/cronsentry/internal/notifications"
+)
+
+// teamsTextLimit keeps the message and output of a card well below the 28 KB
+// Teams accepts for a whole webhook message.
+const teamsTextLimit = 10000
+
+var teamsColors = map[severity]string{
+	severityCritical: "Attention",
+	severityWarning:  "Warning",
+	severityGood:     "Good",
+}
+
+type teamsConfig struct {
+	WebhookURL string `json:"webhook_url"`
+}
+
+// TeamsNotifier posts notifications to a Microsoft Teams webhook as an
+// Adaptive Card.
+type TeamsNotifier struct {
+	client       *http.Client
+	dashboardURL string
+}
+
+func NewTeamsNotifier(client *http.Client, dashboardURL string) *TeamsNotifier {
+	return &TeamsNotifier{
+		client:       client,
+		dashboardURL: dashboardURL,
+	}
+}
+
+func (tn *TeamsNotifier) Validate(config json.RawMessage) error {
+	var cfg teamsConfig
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return fmt.Errorf("invalid teams channel config: %w", err)
+	}
+	return validateURL(cfg.WebhookURL)
+}
+
+func (tn *TeamsNotifier) Notify(ctx context.Context, notification notifications.Notification) error {
+	var cfg teamsConfig
+	if err := json.Unmarshal(notification.Config, &cfg); err != nil {
+		return fmt.Errorf("invalid teams channel config: %w", err)
+	}
+
+	return postJSON(ctx, tn.client, cfg.WebhookURL, nil, tn.message(notification))
+}
+
+func (tn *TeamsNotifier) message(n notifications.Notification) map[string]any {
+	body := []map[string]any{
+		{
+			"type":   "TextBlock",
+			"text":   truncate(n.Content.Title, 256),
+			"size":   "Medium",
+			"weight": "Bolder",
+			"color":  teamsColors[severityOf(n)],
+			"wrap":   true,
+		},
+		{
+			"type": "TextBlock",
+			"text": truncate(n.Content.Message, teamsTextLimit),
+			"wrap": true,
+		},
+		{
+			"type": "FactSet",
+			"facts": []map[string]string{
+				{"title": "Job", "value": n.JobName},
+				{"title": "Schedule", "value": n.ScheduleText()},
+				{"title": "Last ping", "value": formatTime(n.LastPing)},
+				{"title": "Expected", "value": formatTime(n.NextExpect)},
+			},
+		},
+	}
+
+	if n.Output != "" {
+		body = append(body, map[string]any{
+			"type":     "TextBlock",
+			"text":     tail(n.Output, teamsTextLimit),
+			"fontType": "Monospace",
+			"wrap":     true,
+		})
+	}
+
+	card := map[string]any{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	if tn.dashboardURL != "" {
+		card["actions"] = []map[string]any{{
+			"type":  "Action.OpenUrl",
+			"title": "Open dashboard",
+			"url":   tn.dashboardURL,
+		}}
+	}
+
+	return map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	}
+}
@@ -0,0 +1,41 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zigamedved/cronsentry/internal/notifications"
+	"github.com/zigamedved/cronsentry/internal/templates"
+)
+
+func TestTeamsTruncatesOutput(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	config, _ := json.Marshal(teamsConfig{WebhookURL: server.URL})
+	notification := notifications.Notification{
+		JobName: "backup",
+		Output:  strings.Repeat("x", 50000) + "the end",
+		Config:  config,
+		Content: templates.Content{Title: "Job backup failed", Message: strings.Repeat("y", 50000)},
+	}
+
+	if err := NewTeamsNotifier(server.Client(), "").Notify(context.Background(), notification); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	if len(body) > 28*1024 {
+		t.Errorf("message is %d bytes, want at most 28 KB", len(body))
+	}
+	if !strings.Contains(string(body), "the end") {
+		t.Error("message lost the end of the output")
+	}
+}
@@ -0,0 +1,170 @@
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/zigamedved/cronsentry/internal/models"
+	"github.com/zigamedved/cronsentry/internal/notifications"
+)
+
+// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body,
+// keyed with the secret of the channel.
+const SignatureHeader = "X-CronSentry-Signature"
+
+type webhookConfig struct {
+	URL         string            `json:"url"`
+	Headers     map[string]string `json:"headers"`
+	Secret      string            `json:"secret"`
+	Template    string            `json:"template"`     // text/template rendering the body, defaults to JSON
+	ContentType string            `json:"content_type"` // defaults to application/json
+}
+
+// WebhookPayload is the default request body, and the data custom templates
+// are executed with.
+type WebhookPayload struct {
+	NotificationID string              `json:"notification_id"`
+	EventType      models.JobEventType `json:"event_type"`
+	Message        string              `json:"message"`
+	Job            WebhookJob          `json:"job"`
+	PreviousStatus models.JobStatus    `json:"previous_status,omitempty"`
+	Downtime       models.Duration     `json:"downtime,omitempty"`
+	Reminder       int                 `json:"reminder,omitempty"`
+	Output         string              `json:"output,omitempty"`
+	CreatedAt      time.Time           `json:"created_at"`
+}
+
+type WebhookJob struct {
+	ID         string           `json:"id"`
+	Name       string           `json:"name"`
+	Schedule   string           `json:"schedule"`
+	Status     models.JobStatus `json:"status"`
+	LastPing   time.Time        `json:"last_ping"`
+	NextExpect time.Time        `json:"next_expect"`
+}
+
+var webhookFuncs = template.FuncMap{
+	// json encodes a value, so templates can embed strings safely
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// WebhookNotifier posts notifications to a user configured URL.
+type WebhookNotifier struct {
+	client *http.Client
+}
+
+func NewWebhookNotifier(client *http.Client) *WebhookNotifier {
+	return &WebhookNotifier{client: client}
+}
+
+func (wn *WebhookNotifier) Validate(config json.RawMessage) error {
+	cfg, err := parseWebhookConfig(config)
+	if err != nil {
+		return err
+	}
+	if err := validateURL(cfg.URL); err != nil {
+		return err
+	}
+	if cfg.Template != "" {
+		if _, err := template.New("webhook").Funcs(webhookFuncs).Parse(cfg.Template); err != nil {
+			return fmt.Errorf("invalid webhook template: %w", err)
+		}
+	}
+	return nil
+}
+
+func (wn *WebhookNotifier) Notify(ctx context.Context, notification notifications.Notification) error {
+	cfg, err := parseWebhookConfig(notification.Config)
+	if err != nil {
+		return err
+	}
+
+	body, err := renderWebhookBody(cfg.Template, newWebhookPayload(notification))
+	if err != nil {
+		return err
+	}
+
+	headers := make(map[string]string, len(cfg.Headers)+1)
+	for key, value := range cfg.Headers {
+		headers[key] = value
+	}
+	if cfg.Secret != "" {
+		headers[SignatureHeader] = "sha256=" + Sign(cfg.Secret, body)
+	}
+
+	contentType := cfg.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	return post(ctx, wn.client, cfg.URL, contentType, headers, body)
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body. Receivers compare it with
+// the SignatureHeader value to verify a request.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newWebhookPayload(n notifications.Notification) WebhookPayload {
+	return WebhookPayload{
+		NotificationID: n.ID,
+		EventType:      n.EventType,
+		Message:        n.Content.Message,
+		Job: WebhookJob{
+			ID:         n.JobID,
+			Name:       n.JobName,
+			Schedule:   n.ScheduleText(),
+			Status:     n.JobStatus,
+			LastPing:   n.LastPing,
+			NextExpect: n.NextExpect,
+		},
+		PreviousStatus: n.PreviousStatus,
+		Downtime:       n.Downtime,
+		Reminder:       n.Reminder,
+		Output:         n.Output,
+		CreatedAt:      n.CreatedAt,
+	}
+}
+
+func renderWebhookBody(tmpl string, payload WebhookPayload) ([]byte, error) {
+	if tmpl == "" {
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding payload: %w", err)
+		}
+		return body, nil
+	}
+
+	t, err := template.New("webhook").Funcs(webhookFuncs).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, payload); err != nil {
+		return nil, fmt.Errorf("error executing webhook template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func parseWebhookConfig(config json.RawMessage) (webhookConfig, error) {
+	var cfg webhookConfig
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid webhook channel config: %w", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,94 @@
+package integrations
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zigamedved/cronsentry/internal/models"
+	"github.com/zigamedved/cronsentry/internal/notifications"
+	"github.com/zigamedved/cronsentry/internal/templates"
+)
+
+type capturedRequest struct {
+	header http.Header
+	path   string
+	query  string
+	body   []byte
+}
+
+// captureServer records the requests it receives.
+func captureServer(t *testing.T) (*httptest.Server, *[]capturedRequest) {
+	var requests []capturedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, capturedRequest{header: r.Header, path: r.URL.EscapedPath(), query: r.URL.RawQuery, body: body})
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestWebhookSignsBody(t *testing.T) {
+	server, requests := captureServer(t)
+
+	tests := []struct {
+		name     string
+		config   webhookConfig
+		wantBody string
+	}{
+		{name: "default body", config: webhookConfig{URL: server.URL, Secret: "s3cret"}},
+		{
+			name:     "template",
+			config:   webhookConfig{URL: server.URL, Secret: "s3cret", Template: `{"text": {{json .Message}}}`},
+			wantBody: `{"text": "Job backup failed"}`,
+		},
+		{name: "unsigned", config: webhookConfig{URL: server.URL}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*requests = nil
+			config, _ := json.Marshal(tt.config)
+			notification := notifications.Notification{
+				ID:        "notification-1",
+				EventType: models.TypeFail,
+				JobID:     "job-1",
+				JobName:   "backup",
+				Config:    config,
+				Content:   templates.Content{Title: "Failed", Message: "Job backup failed"},
+			}
+
+			if err := NewWebhookNotifier(server.Client()).Notify(context.Background(), notification); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+			if len(*requests) != 1 {
+				t.Fatalf("got %d requests, want 1", len(*requests))
+			}
+			request := (*requests)[0]
+
+			if tt.wantBody != "" && string(request.body) != tt.wantBody {
+				t.Errorf("body = %s, want %s", request.body, tt.wantBody)
+			}
+
+			signature := request.header.Get(SignatureHeader)
+			if tt.config.Secret == "" {
+				if signature != "" {
+					t.Errorf("unsigned request has %s %q", SignatureHeader, signature)
+				}
+				return
+			}
+
+			mac := hmac.New(sha256.New, []byte(tt.config.Secret))
+			mac.Write(request.body)
+			if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+				t.Errorf("%s = %q, want %q", SignatureHeader, signature, want)
+			}
+		})
+	}
+}
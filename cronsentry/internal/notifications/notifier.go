@@ -0,0 +1,100 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/zigamedved/cronsentry/internal/models"
+	"github.com/zigamedved/cronsentry/internal/templates"
+)
+
+// Notification is a queued alert together with what a channel needs to
+// render it.
+type Notification struct {
+	ID             string
+	Type           string
+	Attempts       int // failed sends so far
+	EventType      models.JobEventType
+	Output         string // body of the ping that caused the alert, if any
+	ExitCode       *int
+	UserID         string
+	UserEmail      string
+	JobID          string
+	JobName        string
+	JobStatus      models.JobStatus
+	PreviousStatus models.JobStatus // job status before the event, empty when unknown
+	Downtime       models.Duration  // how long the job was down, for recoveries
+	Reminder       int              // number of the reminder, for reminders
+	Schedule       string
+	Period         models.Duration // set instead of Schedule for period jobs
+	MaxRuntime     models.Duration
+	LastPing       time.Time
+	NextExpect     time.Time
+	CreatedAt      time.Time
+	Config         json.RawMessage // settings of the channel, empty for the default email
+	Content        templates.Content
+}
+
+// ScheduleText describes when the job is expected to run.
+func (n Notification) ScheduleText() string {
+	if n.Schedule == "" && n.Period > 0 {
+		return "every " + n.Period.Duration().String()
+	}
+	return n.Schedule
+}
+
+func (n Notification) templateData() templates.Data {
+	return templates.Data{
+		Job: templates.Job{
+			ID:         n.JobID,
+			Name:       n.JobName,
+			Schedule:   n.ScheduleText(),
+			Status:     n.JobStatus,
+			LastPing:   n.LastPing,
+			NextExpect: n.NextExpect,
+			MaxRuntime: n.MaxRuntime.Duration(),
+		},
+		EventType:      n.EventType,
+		PreviousStatus: n.PreviousStatus,
+		Output:         n.Output,
+		ExitCode:       n.ExitCode,
+		Downtime:       n.Downtime.Duration(),
+		Reminder:       n.Reminder,
+		Time:           n.CreatedAt,
+	}
+}
+
+// Notifier delivers notifications over one channel type.
+type Notifier interface {
+	// Validate checks the channel settings before they are saved.
+	Validate(config json.RawMessage) error
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// Registry maps channel types to their notifiers.
+type Registry struct {
+	notifiers map[string]Notifier
+}
+
+func NewRegistry() *Registry {
+	return &Registry{notifiers: make(map[string]Notifier)}
+}
+
+func (r *Registry) Register(channelType string, notifier Notifier) {
+	r.notifiers[channelType] = notifier
+}
+
+func (r *Registry) Get(channelType string) (Notifier, bool) {
+	notifier, ok := r.notifiers[channelType]
+	return notifier, ok
+}
+
+func (r *Registry) Validate(channelType string, config json.RawMessage) error {
+	notifier, ok := r.notifiers[channelType]
+	if !ok {
+		return fmt.Errorf("unsupported channel type %q", channelType)
+	}
+	return notifier.Validate(config)
+}
@@ -1,39 +1,74 @@
 package notifications
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
+	"math/rand/v2"
+	"sync"
 	"time"
+
+	"github.com/lib/pq"
+	"github.com/zigamedved/cronsentry/internal/templates"
 )
 
+// sendTimeout bounds how long a single notifier may take.
+const sendTimeout = 30 * time.Second
+
+// Failed sends are retried with exponential backoff until maxAttempts, after
+// which the notification is dead-lettered.
+const (
+	maxAttempts    = 8
+	retryBaseDelay = 30 * time.Second
+	retryMaxDelay  = time.Hour
+)
+
+// pollInterval is how often the queue is checked without being woken, for
+// retries that became due and leases that expired.
+const pollInterval = 10 * time.Second
+
+// workers is how many notifications are sent at the same time.
+const workers = 4
+
+// leaseDuration is how long a claimed notification is reserved for the
+// processor sending it. Should the processor die while sending, another one
+// claims the notification once the lease expired.
+const leaseDuration = 2 * sendTimeout
+
 type NotificationProcessor struct {
-	db          *sql.DB
-	emailSender EmailSender
-	logger      *log.Logger
-	done        chan struct{}
+	db       *sql.DB
+	listener *pq.Listener
+	registry *Registry
+	renderer *templates.Renderer
+	logger   *log.Logger
+	done     chan struct{}
 }
 
-func NewNotificationProcessor(db *sql.DB, emailSender EmailSender, logger *log.Logger) *NotificationProcessor {
+// NewNotificationProcessor sends the queued notifications. The listener wakes
+// it as soon as a notification is queued.
+func NewNotificationProcessor(db *sql.DB, listener *pq.Listener, registry *Registry, renderer *templates.Renderer, logger *log.Logger) *NotificationProcessor {
 	return &NotificationProcessor{
-		db:          db,
-		emailSender: emailSender,
-		logger:      logger,
-		done:        make(chan struct{}),
+		db:       db,
+		listener: listener,
+		registry: registry,
+		renderer: renderer,
+		logger:   logger,
+		done:     make(chan struct{}),
 	}
 }
 
 func (np *NotificationProcessor) Start() {
 	go func() {
-		ticker := time.NewTicker(30 * time.Second)
+		ticker := time.NewTicker(pollInterval)
 		defer ticker.Stop()
 
 		for {
+			np.processNotifications()
+
 			select {
+			case <-np.listener.Notify:
 			case <-ticker.C:
-				if err := np.processNotifications(); err != nil {
-					np.logger.Printf("Error processing notifications: %v", err)
-				}
 			case <-np.done:
 				return
 			}
@@ -43,86 +78,189 @@ func (np *NotificationProcessor) Start() {
 
 func (np *NotificationProcessor) Stop() {
 	close(np.done)
+	np.listener.Close()
+}
+
+// processNotifications sends notifications until none is due.
+func (np *NotificationProcessor) processNotifications() {
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-np.done:
+					return
+				default:
+				}
+
+				processed, err := np.processNextNotification()
+				if err != nil {
+					np.logger.Printf("Error processing notifications: %v", err)
+					return
+				}
+				if !processed {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
 }
 
-func (np *NotificationProcessor) processNotifications() error {
+// processNextNotification claims one due notification and sends it. Claiming
+// takes a lease on the row in a transaction of its own, so the row is not
+// locked while sending and other processors claim different notifications
+// meanwhile.
+func (np *NotificationProcessor) processNextNotification() (bool, error) {
+	var id string
+	err := np.db.QueryRow(`
+		UPDATE notifications
+		SET status = 'sending', locked_until = $1
+		WHERE id = (
+			SELECT id
+			FROM notifications
+			WHERE (status = 'pending' AND (next_attempt_at IS NULL OR next_attempt_at <= NOW()))
+			   OR (status = 'sending' AND locked_until <= NOW())
+			ORDER BY COALESCE(next_attempt_at, created_at)
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id
+	`, time.Now().UTC().Add(leaseDuration)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error claiming notification: %w", err)
+	}
+
 	query := `
-		SELECT n.id, n.message, n.type, u.email, j.name
+		SELECT n.id, n.type, n.attempts, n.created_at, COALESCE(n.previous_status, ''), n.downtime,
+		       n.user_id, u.email, j.id, j.name, j.status, j.schedule, j.period, j.max_runtime, j.last_ping,
+		       j.next_expect, COALESCE(e.type, ''), COALESCE(e.data->>'body', ''), (e.data->>'exit_code')::int,
+		       COALESCE((e.data->>'reminder')::int, 0), COALESCE(c.config, '{}')
 		FROM notifications n
 		JOIN users u ON n.user_id = u.id
 		JOIN jobs j ON n.job_id = j.id
-		WHERE n.status = 'pending'
-		LIMIT 10
+		LEFT JOIN job_events e ON n.event_id = e.id
+		LEFT JOIN channels c ON n.channel_id = c.id
+		WHERE n.id = $1
 	`
 
-	rows, err := np.db.Query(query)
+	var notification Notification
+	var lastPing, nextExpect sql.NullTime
+	var exitCode sql.NullInt64
+	err = np.db.QueryRow(query, id).Scan(
+		&notification.ID, &notification.Type, &notification.Attempts, &notification.CreatedAt,
+		&notification.PreviousStatus, &notification.Downtime, &notification.UserID, &notification.UserEmail,
+		&notification.JobID, &notification.JobName, &notification.JobStatus, &notification.Schedule, &notification.Period,
+		&notification.MaxRuntime, &lastPing, &nextExpect, &notification.EventType, &notification.Output, &exitCode,
+		&notification.Reminder, &notification.Config,
+	)
+	if err == sql.ErrNoRows {
+		// the job was deleted in the meantime, taking the notification along
+		return true, nil
+	}
 	if err != nil {
-		return fmt.Errorf("error querying notifications: %w", err)
+		return false, fmt.Errorf("error querying notification %s: %w", id, err)
+	}
+	notification.LastPing = lastPing.Time
+	notification.NextExpect = nextExpect.Time
+	if exitCode.Valid {
+		code := int(exitCode.Int64)
+		notification.ExitCode = &code
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var notification struct {
-			ID      string
-			Message string
-			Type    string
-			Email   string
-			JobName string
+	overrides, err := np.templateOverrides(notification)
+	if err != nil {
+		if err := np.markNotificationFailed(notification, err.Error()); err != nil {
+			np.logger.Printf("Error marking notification as failed: %v", err)
 		}
+		return false, err
+	}
 
-		if err := rows.Scan(
-			&notification.ID,
-			&notification.Message,
-			&notification.Type,
-			&notification.Email,
-			&notification.JobName,
-		); err != nil {
-			return fmt.Errorf("error scanning notification: %w", err)
+	if err := np.render(&notification, overrides); err != nil {
+		// rendering the same templates again fails the same way
+		np.logger.Printf("Error processing notification %s: %v", notification.ID, err)
+		if err := np.markNotificationDead(notification.ID, err.Error()); err != nil {
+			return false, err
 		}
+		return true, nil
+	}
 
-		var processErr error
-		if notification.Type == "email" {
-			processErr = np.sendEmailNotification(notification.ID, notification.Email, notification.JobName, notification.Message)
-		} else {
-			np.logger.Printf("Unsupported notification type: %s", notification.Type)
-			processErr = np.markNotificationFailed(notification.ID, fmt.Sprintf("Unsupported type: %s", notification.Type))
-		}
+	if err := np.send(notification); err != nil {
+		np.logger.Printf("Error processing notification %s: %v", notification.ID, err)
+	}
 
-		if processErr != nil {
-			np.logger.Printf("Error processing notification %s: %v", notification.ID, processErr)
-		}
+	return true, nil
+}
+
+// templateOverrides returns the templates the user set for the channel and
+// event type of the notification.
+func (np *NotificationProcessor) templateOverrides(notification Notification) (templates.Overrides, error) {
+	rows, err := np.db.Query(`
+		SELECT part, body
+		FROM templates
+		WHERE user_id = $1 AND channel = $2 AND event_type = $3
+	`, notification.UserID, notification.Type, notification.EventType)
+	if err != nil {
+		return nil, fmt.Errorf("error querying templates: %w", err)
 	}
+	defer rows.Close()
 
+	overrides := make(templates.Overrides)
+	for rows.Next() {
+		var part templates.Part
+		var body string
+		if err := rows.Scan(&part, &body); err != nil {
+			return nil, fmt.Errorf("error scanning template row: %w", err)
+		}
+		overrides[part] = body
+	}
 	if err := rows.Err(); err != nil {
-		return fmt.Errorf("error iterating notifications: %w", err)
+		return nil, fmt.Errorf("error iterating template rows: %w", err)
 	}
 
+	return overrides, nil
+}
+
+// render fills in the content of the notification from the templates of the
+// user. Broken overrides fall back to the defaults, so the alert still goes out.
+func (np *NotificationProcessor) render(notification *Notification, overrides templates.Overrides) error {
+	data := notification.templateData()
+	content, err := np.renderer.Render(notification.Type, notification.EventType, overrides, data)
+	if err != nil && len(overrides) > 0 {
+		np.logger.Printf("Error rendering templates of notification %s, using defaults: %v", notification.ID, err)
+		content, err = np.renderer.Render(notification.Type, notification.EventType, nil, data)
+	}
+	if err != nil {
+		return fmt.Errorf("error rendering notification %s: %w", notification.ID, err)
+	}
+
+	notification.Content = content
 	return nil
 }
 
-func (np *NotificationProcessor) sendEmailNotification(id, email, jobName, message string) error {
-	subject := fmt.Sprintf("CronSentry Alert: Job '%s'", jobName)
-	body := fmt.Sprintf(`
-		<html>
-			<body>
-				<h2>CronSentry Alert</h2>
-				<p>%s</p>
-				<p>Job: <strong>%s</strong></p>
-				<p>Time: <strong>%s</strong></p>
-				<hr>
-				<p>View details in your <a href="https://cronsentry.example.com/dashboard">CronSentry Dashboard</a></p>
-			</body>
-		</html>
-	`, message, jobName, time.Now().Format(time.RFC1123))
-
-	if err := np.emailSender.SendEmail(email, subject, body); err != nil {
-		if err := np.markNotificationFailed(id, err.Error()); err != nil {
+func (np *NotificationProcessor) send(notification Notification) error {
+	notifier, ok := np.registry.Get(notification.Type)
+	if !ok {
+		np.logger.Printf("Unsupported notification type: %s", notification.Type)
+		return np.markNotificationDead(notification.ID, fmt.Sprintf("Unsupported type: %s", notification.Type))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	if err := notifier.Notify(ctx, notification); err != nil {
+		if err := np.markNotificationFailed(notification, err.Error()); err != nil {
 			np.logger.Printf("Error marking notification as failed: %v", err)
 		}
-		return fmt.Errorf("error sending email: %w", err)
+		return fmt.Errorf("error sending %s notification (attempt %d): %w", notification.Type, notification.Attempts+1, err)
 	}
 
-	if err := np.markNotificationSent(id); err != nil {
+	if err := np.markNotificationSent(notification.ID); err != nil {
 		return fmt.Errorf("error marking notification as sent: %w", err)
 	}
 
@@ -132,7 +270,7 @@ func (np *NotificationProcessor) sendEmailNotification(id, email, jobName, messa
 func (np *NotificationProcessor) markNotificationSent(id string) error {
 	query := `
 		UPDATE notifications
-		SET status = 'sent', sent_at = $1
+		SET status = 'sent', sent_at = $1, attempts = attempts + 1, locked_until = NULL
 		WHERE id = $2
 	`
 
@@ -144,17 +282,51 @@ func (np *NotificationProcessor) markNotificationSent(id string) error {
 	return nil
 }
 
-func (np *NotificationProcessor) markNotificationFailed(id, reason string) error {
+// markNotificationFailed schedules the next attempt, or dead-letters the
+// notification once it ran out of attempts.
+func (np *NotificationProcessor) markNotificationFailed(notification Notification, reason string) error {
+	attempts := notification.Attempts + 1
+	if attempts >= maxAttempts {
+		np.logger.Printf("Notification %s failed %d times, moving it to the dead letters", notification.ID, attempts)
+		return np.markNotificationDead(notification.ID, reason)
+	}
+
+	query := `
+		UPDATE notifications
+		SET status = 'pending', attempts = $1, next_attempt_at = $2, last_error = $3, locked_until = NULL
+		WHERE id = $4
+	`
+
+	_, err := np.db.Exec(query, attempts, time.Now().UTC().Add(retryDelay(attempts)), reason, notification.ID)
+	if err != nil {
+		return fmt.Errorf("error updating notification: %w", err)
+	}
+
+	return nil
+}
+
+func (np *NotificationProcessor) markNotificationDead(id, reason string) error {
 	query := `
 		UPDATE notifications
-		SET status = 'failed', data = jsonb_set(COALESCE(data, '{}'::jsonb), '{error}', $1)
+		SET status = 'dead', attempts = attempts + 1, next_attempt_at = NULL, last_error = $1, locked_until = NULL
 		WHERE id = $2
 	`
 
-	_, err := np.db.Exec(query, fmt.Sprintf("\"%s\"", reason), id)
+	_, err := np.db.Exec(query, reason, id)
 	if err != nil {
 		return fmt.Errorf("error updating notification: %w", err)
 	}
 
 	return nil
 }
+
+// retryDelay doubles the delay with every attempt, up to retryMaxDelay. The
+// delay is jittered between half and the full value, so notifications that
+// failed together are not retried in lockstep.
+func retryDelay(attempts int) time.Duration {
+	delay := retryMaxDelay
+	if shift := attempts - 1; shift < 20 {
+		delay = min(retryBaseDelay<<shift, retryMaxDelay)
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
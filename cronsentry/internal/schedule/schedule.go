@@ -0,0 +1,107 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adhocore/gronx"
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+// maxSkips bounds how many ticks NextTick skips over while resolving
+// ambiguous wall clock times around DST transitions.
+const maxSkips = 5
+
+func LoadLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+	return loc, nil
+}
+
+// Validate checks that the job describes a schedule NextExpect can evaluate.
+// The returned errors are meant to be shown to API clients.
+func Validate(job *models.Job) error {
+	switch job.Kind {
+	case models.KindCron:
+		if job.Schedule == "" {
+			return errors.New("Schedule is required for cron jobs")
+		}
+		if !gronx.IsValid(job.Schedule) {
+			return errors.New("Invalid CRON schedule provided")
+		}
+	case models.KindPeriod:
+		if job.Period.Duration() < time.Second {
+			return errors.New("Period of at least one second is required for period jobs")
+		}
+	default:
+		return fmt.Errorf("Invalid job kind %q", job.Kind)
+	}
+
+	if _, err := LoadLocation(job.Timezone); err != nil {
+		return errors.New("Invalid timezone provided")
+	}
+
+	return nil
+}
+
+// NextExpect returns when the next ping of the job is due after ref.
+func NextExpect(job *models.Job, ref time.Time) (time.Time, error) {
+	if job.Kind == models.KindPeriod {
+		return ref.Add(job.Period.Duration()).UTC(), nil
+	}
+	return NextTick(job.Schedule, job.Timezone, ref)
+}
+
+// NextTick returns the first time after ref at which the cron expression is
+// due on the wall clock of the given IANA time zone.
+//
+// The expression is evaluated on the local wall clock without DST, and the
+// result is then placed in the zone. Wall clock times skipped by a spring
+// forward transition are shifted forward by the length of the gap, and times
+// repeated when clocks fall back resolve to their first occurrence, so DST
+// never makes a run expected earlier than the cron daemon would start it.
+func NextTick(expr, timezone string, ref time.Time) (time.Time, error) {
+	loc, err := LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	wall := toWallClock(ref.In(loc))
+	for i := 0; i < maxSkips; i++ {
+		wall, err = gronx.NextTickAfter(expr, wall, false)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("error calculating next tick: %w", err)
+		}
+
+		next := fromWallClock(wall, loc)
+		if next.After(ref) {
+			return next.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("no tick of %q found after %s", expr, ref)
+}
+
+func toWallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+func fromWallClock(wall time.Time, loc *time.Location) time.Time {
+	t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), loc)
+	if toWallClock(t).Equal(wall) {
+		return t
+	}
+
+	// the wall clock time falls into a DST gap, interpreting it with the
+	// smaller of the two offsets around the transition gives the later instant
+	_, before := t.Add(-12 * time.Hour).Zone()
+	_, after := t.Add(12 * time.Hour).Zone()
+	shifted := wall.Add(-time.Duration(min(before, after)) * time.Second)
+	return shifted.In(loc)
+}
@@ -0,0 +1,144 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+func newYork(t *testing.T) *time.Location {
+	t.Helper()
+
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+	return loc
+}
+
+func TestNextTickDST(t *testing.T) {
+	loc := newYork(t)
+	edt := time.FixedZone("EDT", -4*60*60)
+	est := time.FixedZone("EST", -5*60*60)
+
+	// clocks spring forward from 02:00 EST to 03:00 EDT on 2024-03-10, and
+	// fall back from 02:00 EDT to 01:00 EST on 2024-11-03
+	tests := []struct {
+		name string
+		expr string
+		ref  time.Time
+		want time.Time
+	}{
+		{
+			name: "skipped time runs after the gap",
+			expr: "30 2 * * *",
+			ref:  time.Date(2024, 3, 10, 0, 0, 0, 0, est),
+			want: time.Date(2024, 3, 10, 3, 30, 0, 0, edt),
+		},
+		{
+			name: "hourly across the gap",
+			expr: "0 * * * *",
+			ref:  time.Date(2024, 3, 10, 1, 0, 0, 0, est),
+			want: time.Date(2024, 3, 10, 3, 0, 0, 0, edt),
+		},
+		{
+			name: "day after the gap",
+			expr: "30 2 * * *",
+			ref:  time.Date(2024, 3, 10, 3, 30, 0, 0, edt),
+			want: time.Date(2024, 3, 11, 2, 30, 0, 0, edt),
+		},
+		{
+			name: "repeated time runs at the first occurrence",
+			expr: "30 1 * * *",
+			ref:  time.Date(2024, 11, 3, 0, 0, 0, 0, edt),
+			want: time.Date(2024, 11, 3, 1, 30, 0, 0, edt),
+		},
+		{
+			name: "repeated time runs once",
+			expr: "30 1 * * *",
+			ref:  time.Date(2024, 11, 3, 1, 30, 0, 0, edt),
+			want: time.Date(2024, 11, 4, 1, 30, 0, 0, est),
+		},
+		{
+			name: "repeated time runs once, ref in the second occurrence",
+			expr: "30 1 * * *",
+			ref:  time.Date(2024, 11, 3, 1, 0, 0, 0, est),
+			want: time.Date(2024, 11, 4, 1, 30, 0, 0, est),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NextTick(tt.expr, loc.String(), tt.ref)
+			if err != nil {
+				t.Fatalf("NextTick() error = %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("NextTick() = %s, want %s", got.In(loc), tt.want.In(loc))
+			}
+		})
+	}
+}
+
+// A job pinged by cron at every run around a transition must never be due
+// before its next run, otherwise the job checker flags it as missed.
+func TestNextExpectAcrossDST(t *testing.T) {
+	loc := newYork(t)
+
+	tests := []struct {
+		name     string
+		schedule string
+		runs     []time.Time // when cron starts the job, in wall clock time
+	}{
+		{
+			name:     "spring forward",
+			schedule: "30 2 * * *",
+			runs: []time.Time{
+				time.Date(2024, 3, 9, 2, 30, 0, 0, loc),
+				// cron starts jobs of the skipped hour right after the gap
+				time.Date(2024, 3, 10, 3, 0, 0, 0, loc),
+				time.Date(2024, 3, 11, 2, 30, 0, 0, loc),
+			},
+		},
+		{
+			name:     "fall back",
+			schedule: "30 1 * * *",
+			runs: []time.Time{
+				time.Date(2024, 11, 2, 1, 30, 0, 0, loc),
+				time.Date(2024, 11, 3, 1, 30, 0, 0, loc),
+				time.Date(2024, 11, 4, 1, 30, 0, 0, loc),
+			},
+		},
+		{
+			name:     "hourly over spring forward",
+			schedule: "0 * * * *",
+			runs: []time.Time{
+				time.Date(2024, 3, 10, 0, 0, 0, 0, loc),
+				time.Date(2024, 3, 10, 1, 0, 0, 0, loc),
+				time.Date(2024, 3, 10, 3, 0, 0, 0, loc),
+				time.Date(2024, 3, 10, 4, 0, 0, 0, loc),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &models.Job{Kind: models.KindCron, Schedule: tt.schedule, Timezone: loc.String()}
+
+			for i, ping := range tt.runs[:len(tt.runs)-1] {
+				next, err := NextExpect(job, ping)
+				if err != nil {
+					t.Fatalf("NextExpect() error = %v", err)
+				}
+				if run := tt.runs[i+1]; next.Before(run) {
+					t.Errorf("after the ping at %s the job is due at %s, before its run at %s",
+						ping, next.In(loc), run)
+				}
+				if next.Sub(ping) > 25*time.Hour {
+					t.Errorf("after the ping at %s the job is due only at %s", ping, next.In(loc))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+// Package sso signs users in with an OpenID Connect provider, using the
+// authorization code flow with PKCE. Any compliant provider works, the
+// endpoints are taken from its discovery document.
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/zigamedved/cronsentry/internal/models"
+	"golang.org/x/oauth2"
+)
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // empty for public clients, PKCE protects the flow
+	RedirectURL  string // the callback endpoint of the API
+	Scopes       []string
+	GroupsClaim  string // ID token claim listing the groups of the user
+	GroupMapping GroupMapping
+}
+
+// Identity is what the provider tells about a signed in user.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+}
+
+// Provider runs the login flow against the configured issuer. Discovery
+// happens on first use and is retried until it succeeds, so the API starts
+// even while the provider is unreachable.
+type Provider struct {
+	config Config
+
+	mu          sync.Mutex
+	oauthConfig *oauth2.Config
+	verifier    *oidc.IDTokenVerifier
+}
+
+func NewProvider(config Config) *Provider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	return &Provider{config: config}
+}
+
+func (p *Provider) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauthConfig != nil {
+		return p.oauthConfig, p.verifier, nil
+	}
+
+	// the key set keeps using the context to refresh keys, so it must outlive
+	// the request that happens to discover the provider
+	provider, err := oidc.NewProvider(context.WithoutCancel(ctx), p.config.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error discovering OIDC provider: %w", err)
+	}
+
+	p.oauthConfig = &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  p.config.RedirectURL,
+		Scopes:       p.config.Scopes,
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.config.ClientID})
+
+	return p.oauthConfig, p.verifier, nil
+}
+
+// Flow is the state of a login between sending the user to the provider and
+// the callback. It has to be kept by the client, e.g. in a cookie.
+type Flow struct {
+	State    string
+	Nonce    string
+	Verifier string // PKCE code verifier
+}
+
+// Encode returns the flow as a string that is safe to use as a cookie value.
+func (f Flow) Encode() string {
+	return f.State + "." + f.Nonce + "." + f.Verifier
+}
+
+func DecodeFlow(s string) (Flow, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return Flow{}, errors.New("invalid login flow")
+	}
+	return Flow{State: parts[0], Nonce: parts[1], Verifier: parts[2]}, nil
+}
+
+// Start begins a login. It returns where to send the user to sign in, and the
+// flow to pass to Exchange in the callback.
+func (p *Provider) Start(ctx context.Context) (string, Flow, error) {
+	config, _, err := p.discover(ctx)
+	if err != nil {
+		return "", Flow{}, err
+	}
+
+	flow := Flow{State: random(), Nonce: random(), Verifier: oauth2.GenerateVerifier()}
+	url := config.AuthCodeURL(flow.State, oidc.Nonce(flow.Nonce), oauth2.S256ChallengeOption(flow.Verifier))
+	return url, flow, nil
+}
+
+// Exchange redeems the code of the callback and verifies the ID token that
+// comes with it. The caller has to check that the state of the callback
+// matches the flow.
+func (p *Provider) Exchange(ctx context.Context, code string, flow Flow) (*Identity, error) {
+	config, idVerifier, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("error exchanging code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("no id_token in token response")
+	}
+
+	idToken, err := idVerifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("error verifying ID token: %w", err)
+	}
+
+	if idToken.Nonce != flow.Nonce {
+		return nil, errors.New("ID token nonce does not match")
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("error decoding ID token claims: %w", err)
+	}
+
+	identity := &Identity{
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+		Groups:  stringList(claims[p.config.GroupsClaim]),
+	}
+	identity.Email, _ = claims["email"].(string)
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	identity.Name, _ = claims["name"].(string)
+	if identity.Name == "" {
+		identity.Name, _ = claims["preferred_username"].(string)
+	}
+
+	return identity, nil
+}
+
+// Teams returns the teams and roles the groups of the identity map to.
+func (p *Provider) Teams(identity *Identity) map[string]models.TeamRole {
+	return p.config.GroupMapping.Teams(identity.Groups)
+}
+
+func random() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("error generating random value: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// stringList reads a claim that is a list of strings, or a single string as
+// some providers send when there is only one group.
+func stringList(claim any) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []any:
+		var list []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// GroupMapping maps provider groups to the teams and roles they grant.
+type GroupMapping map[string][]TeamGrant
+
+type TeamGrant struct {
+	Team string
+	Role models.TeamRole
+}
+
+// ParseGroupMapping parses mappings of the form
+// "group=team:role,other-group=team", where the role defaults to member.
+// A group may map to several teams.
+func ParseGroupMapping(s string) (GroupMapping, error) {
+	mapping := make(GroupMapping)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		group, grant, ok := strings.Cut(entry, "=")
+		if !ok || group == "" || grant == "" {
+			return nil, fmt.Errorf("invalid group mapping %q, expected group=team:role", entry)
+		}
+
+		team, role, _ := strings.Cut(grant, ":")
+		if team == "" {
+			return nil, fmt.Errorf("invalid group mapping %q, team is missing", entry)
+		}
+		if role == "" {
+			role = string(models.RoleMember)
+		}
+		if !models.TeamRole(role).Valid() {
+			return nil, fmt.Errorf("invalid role %q in group mapping %q", role, entry)
+		}
+
+		mapping[group] = append(mapping[group], TeamGrant{Team: team, Role: models.TeamRole(role)})
+	}
+	return mapping, nil
+}
+
+// Teams returns the teams the groups map to. When several groups grant the
+// same team, the highest role wins.
+func (m GroupMapping) Teams(groups []string) map[string]models.TeamRole {
+	teams := make(map[string]models.TeamRole)
+	for _, group := range groups {
+		for _, grant := range m[group] {
+			if role, ok := teams[grant.Team]; !ok || grant.Role.Outranks(role) {
+				teams[grant.Team] = grant.Role
+			}
+		}
+	}
+	return teams
+}
@@ -0,0 +1,73 @@
+package sso
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+func TestParseGroupMapping(t *testing.T) {
+	mapping, err := ParseGroupMapping("sre=platform:admin, dev=platform,dev=web:member,,")
+	if err != nil {
+		t.Fatalf("ParseGroupMapping() error = %v", err)
+	}
+
+	want := GroupMapping{
+		"sre": {{Team: "platform", Role: models.RoleAdmin}},
+		"dev": {{Team: "platform", Role: models.RoleMember}, {Team: "web", Role: models.RoleMember}},
+	}
+	if !reflect.DeepEqual(mapping, want) {
+		t.Errorf("ParseGroupMapping() = %v, want %v", mapping, want)
+	}
+
+	for _, invalid := range []string{"sre", "=platform", "sre=", "sre=:admin", "sre=platform:owner"} {
+		if _, err := ParseGroupMapping(invalid); err == nil {
+			t.Errorf("ParseGroupMapping(%q) succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestGroupMappingTeams(t *testing.T) {
+	mapping := GroupMapping{
+		"sre": {{Team: "platform", Role: models.RoleAdmin}},
+		"dev": {{Team: "platform", Role: models.RoleMember}, {Team: "web", Role: models.RoleMember}},
+	}
+
+	tests := []struct {
+		name   string
+		groups []string
+		want   map[string]models.TeamRole
+	}{
+		{name: "no groups", want: map[string]models.TeamRole{}},
+		{name: "unmapped group", groups: []string{"finance"}, want: map[string]models.TeamRole{}},
+		{name: "several teams", groups: []string{"dev"}, want: map[string]models.TeamRole{"platform": models.RoleMember, "web": models.RoleMember}},
+		{name: "highest role wins", groups: []string{"dev", "sre"}, want: map[string]models.TeamRole{"platform": models.RoleAdmin, "web": models.RoleMember}},
+		{name: "highest role wins in any order", groups: []string{"sre", "dev"}, want: map[string]models.TeamRole{"platform": models.RoleAdmin, "web": models.RoleMember}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapping.Teams(tt.groups); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Teams() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringList(t *testing.T) {
+	tests := []struct {
+		claim any
+		want  []string
+	}{
+		{claim: []any{"ops", 1, "dev"}, want: []string{"ops", "dev"}},
+		{claim: "ops", want: []string{"ops"}},
+		{claim: nil},
+	}
+
+	for _, tt := range tests {
+		if got := stringList(tt.claim); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("stringList(%v) = %v, want %v", tt.claim, got, tt.want)
+		}
+	}
+}
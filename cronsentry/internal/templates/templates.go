@@ -0,0 +1,265 @@
+// Package templates renders the content of notifications. Every channel gets
+// a title and a message, email additionally a plain text and an HTML body.
+// Other channels have no body templates, they place the title and message in
+// a layout of their own.
+// Defaults are embedded in the binary and users can override any part per
+// channel and event type.
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/zigamedved/cronsentry/internal/models"
+)
+
+//go:embed defaults
+var defaultFiles embed.FS
+
+type Part string
+
+const (
+	PartTitle   Part = "title"   // one line summary, e.g. the email subject
+	PartMessage Part = "message" // short description of what happened
+	PartText    Part = "text"    // plain text email body
+	PartHTML    Part = "html"    // HTML email body
+)
+
+// EventTypes are the events that notifications are sent for.
+var EventTypes = []models.JobEventType{
+	models.TypeMiss,
+	models.TypeFail,
+	models.TypeRecovery,
+	models.TypeLongRunning,
+	models.TypeReminder,
+}
+
+// bodyParts are rendered after the title and message, and can use both.
+var bodyParts = map[string][]Part{
+	models.ChannelEmail: {PartText, PartHTML},
+}
+
+// PartsFor returns the parts rendered for a channel type.
+func PartsFor(channel string) []Part {
+	return append([]Part{PartTitle, PartMessage}, bodyParts[channel]...)
+}
+
+// Data is what templates are executed with.
+type Data struct {
+	Job            Job
+	EventType      models.JobEventType
+	PreviousStatus models.JobStatus
+	Output         string        // body of the ping that caused the alert
+	ExitCode       *int          // exit code the job reported, if any
+	Downtime       time.Duration // how long the job was down, for recoveries
+	Reminder       int           // number of the reminder, for reminders
+	Time           time.Time
+	DashboardURL   string
+
+	// Title and Message hold the rendered title and message parts, for the
+	// body parts.
+	Title   string
+	Message string
+}
+
+type Job struct {
+	ID         string
+	Name       string
+	Schedule   string // cron expression, or "every <period>"
+	Status     models.JobStatus
+	LastPing   time.Time
+	NextExpect time.Time
+	MaxRuntime time.Duration
+}
+
+// Content is a rendered notification.
+type Content struct {
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	Text    string `json:"text,omitempty"`
+	HTML    string `json:"html,omitempty"`
+}
+
+// Overrides are user provided template sources by part.
+type Overrides map[Part]string
+
+var funcs = template.FuncMap{
+	// formatTime renders a time in UTC, or "never" for the zero time
+	"formatTime": func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.UTC().Format("2006-01-02 15:04:05 MST")
+	},
+	"duration": func(d time.Duration) string {
+		return d.Round(time.Second).String()
+	},
+}
+
+// Renderer renders notification content from the embedded defaults and user
+// overrides.
+type Renderer struct {
+	defaults     map[string]string
+	dashboardURL string
+}
+
+func NewRenderer(dashboardURL string) (*Renderer, error) {
+	defaults := make(map[string]string)
+	err := fs.WalkDir(defaultFiles, "defaults", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		source, err := defaultFiles.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(path, "defaults/"), ".tmpl")
+		defaults[name] = string(source)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error loading default templates: %w", err)
+	}
+
+	for name, source := range defaults {
+		part := Part(name[strings.LastIndexAny(name, "/.")+1:])
+		if err := Validate(part, source); err != nil {
+			return nil, fmt.Errorf("default template %s: %w", name, err)
+		}
+	}
+
+	return &Renderer{defaults: defaults, dashboardURL: dashboardURL}, nil
+}
+
+// Validate checks that source parses as a template for part.
+func Validate(part Part, source string) error {
+	var err error
+	if part == PartHTML {
+		_, err = htmltemplate.New(string(part)).Funcs(htmltemplate.FuncMap(funcs)).Parse(source)
+	} else {
+		_, err = template.New(string(part)).Funcs(funcs).Parse(source)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s template: %w", part, err)
+	}
+	return nil
+}
+
+// Render renders every part of a channel. Overrides replace the default of
+// their part.
+func (r *Renderer) Render(channel string, eventType models.JobEventType, overrides Overrides, data Data) (Content, error) {
+	data.EventType = eventType
+	data.DashboardURL = r.dashboardURL
+
+	var content Content
+	for _, part := range PartsFor(channel) {
+		source, ok := overrides[part]
+		if !ok {
+			source = r.Default(channel, eventType, part)
+		}
+
+		output, err := render(part, source, data)
+		if err != nil {
+			return Content{}, err
+		}
+
+		switch part {
+		case PartTitle:
+			content.Title = strings.TrimSpace(output)
+			data.Title = content.Title
+		case PartMessage:
+			content.Message = strings.TrimSpace(output)
+			data.Message = content.Message
+		case PartText:
+			content.Text = output
+		case PartHTML:
+			content.HTML = output
+		}
+	}
+
+	return content, nil
+}
+
+// Default returns the embedded template of a part, preferring the most
+// specific of channel and event type.
+func (r *Renderer) Default(channel string, eventType models.JobEventType, part Part) string {
+	for _, name := range []string{
+		fmt.Sprintf("%s/%s.%s", channel, eventType, part),
+		fmt.Sprintf("%s/%s", channel, part),
+		fmt.Sprintf("%s.%s", eventType, part),
+		string(part),
+	} {
+		if source, ok := r.defaults[name]; ok {
+			return source
+		}
+	}
+	return ""
+}
+
+func render(part Part, source string, data Data) (string, error) {
+	var buf bytes.Buffer
+	if part == PartHTML {
+		t, err := htmltemplate.New(string(part)).Funcs(htmltemplate.FuncMap(funcs)).Parse(source)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s template: %w", part, err)
+		}
+		if err := t.Execute(&buf, data); err != nil {
+			return "", fmt.Errorf("error executing %s template: %w", part, err)
+		}
+		return buf.String(), nil
+	}
+
+	t, err := template.New(string(part)).Funcs(funcs).Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", part, err)
+	}
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("error executing %s template: %w", part, err)
+	}
+	return buf.String(), nil
+}
+
+// SampleData returns made up data for previewing templates.
+func SampleData(eventType models.JobEventType) Data {
+	now := time.Now().UTC().Truncate(time.Second)
+	data := Data{
+		Job: Job{
+			ID:         "00000000-0000-0000-0000-000000000000",
+			Name:       "Database Backup",
+			Schedule:   "0 2 * * *",
+			Status:     models.StatusMissing,
+			LastPing:   now.Add(-25 * time.Hour),
+			NextExpect: now.Add(-time.Hour),
+			MaxRuntime: 2 * time.Hour,
+		},
+		EventType:      eventType,
+		PreviousStatus: models.StatusHealthy,
+		Time:           now,
+	}
+
+	switch eventType {
+	case models.TypeFail:
+		exitCode := 1
+		data.Job.Status = models.StatusFailed
+		data.ExitCode = &exitCode
+		data.Output = "pg_dump: error: connection to server failed"
+	case models.TypeLongRunning:
+		data.Job.Status = models.StatusLongRunning
+	case models.TypeReminder:
+		data.Reminder = 2
+	case models.TypeRecovery:
+		data.Job.Status = models.StatusHealthy
+		data.Job.LastPing = now
+		data.Job.NextExpect = now.Add(24 * time.Hour)
+		data.PreviousStatus = models.StatusMissing
+		data.Downtime = 47 * time.Minute
+	}
+
+	return data
+}
@@ -2,6 +2,7 @@ import { useState, useEffect } from 'react';
 import { JobCard } from './components/JobCard';
 import { Stats } from './components/Stats';
 import { NewJobModal } from './components/NewJobModal';
+import { LoginForm } from './components/LoginForm';
 import { PlusIcon } from '@heroicons/react/24/outline';
 
 const API_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080';
@@ -11,30 +12,101 @@ interface Job {
   name: string;
   description: string;
   schedule: string;
-  status: 'healthy' | 'missing' | 'paused';
+  status: 'healthy' | 'missing' | 'failed' | 'long_running' | 'paused';
   last_ping: string;
   next_expect: string;
 }
 
+interface User {
+  id: string;
+  email: string;
+  name: string;
+}
+
+// api sends the session cookie along with every request
+const api = (path: string, init?: RequestInit) =>
+  fetch(`${API_URL}${path}`, { ...init, credentials: 'include' });
+
 function App() {
   const [jobs, setJobs] = useState<Job[]>([]);
+  const [user, setUser] = useState<User | null>(null);
+  const [ssoEnabled, setSSOEnabled] = useState(false);
+  const [registrationEnabled, setRegistrationEnabled] = useState(false);
   const [isModalOpen, setIsModalOpen] = useState(false);
   const [isLoading, setIsLoading] = useState(true);
 
   useEffect(() => {
-    fetchJobs();
+    fetchUser();
+    api('/api/auth/oidc')
+      .then(response => response.json())
+      .then(data => setSSOEnabled(data.enabled))
+      .catch(error => console.error('Error fetching SSO config:', error));
   }, []);
 
+  // registration closes once the first account exists, unless it is allowed
+  useEffect(() => {
+    if (user) return;
+    api('/api/auth/register')
+      .then(response => response.json())
+      .then(data => setRegistrationEnabled(data.enabled))
+      .catch(error => console.error('Error fetching registration config:', error));
+  }, [user]);
+
+  const fetchUser = async () => {
+    try {
+      const response = await api('/api/auth/me');
+      if (response.status === 401) return;
+      if (!response.ok) throw new Error('Failed to fetch user');
+      setUser(await response.json());
+      await fetchJobs();
+    } catch (error) {
+      console.error('Error fetching user:', error);
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
   const fetchJobs = async () => {
     try {
-      const response = await fetch(`${API_URL}/api/jobs`);
+      const response = await api('/api/jobs');
+      if (response.status === 401) {
+        setUser(null);
+        return;
+      }
       if (!response.ok) throw new Error('Failed to fetch jobs');
       const data = await response.json();
       setJobs(data);
     } catch (error) {
       console.error('Error fetching jobs:', error);
-    } finally {
-      setIsLoading(false);
+    }
+  };
+
+  const handleAuth = async (mode: 'login' | 'register', credentials: { email: string; name: string; password: string }) => {
+    try {
+      const response = await api(`/api/auth/${mode}`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+        },
+        body: JSON.stringify(credentials),
+      });
+      if (!response.ok) return (await response.text()).trim();
+      setUser(await response.json());
+      await fetchJobs();
+      return null;
+    } catch (error) {
+      console.error('Error signing in:', error);
+      return 'Failed to sign in';
+    }
+  };
+
+  const handleLogout = async () => {
+    try {
+      await api('/api/auth/logout', { method: 'POST' });
+      setUser(null);
+      setJobs([]);
+    } catch (error) {
+      console.error('Error signing out:', error);
     }
   };
 
@@ -46,7 +118,7 @@ function App() {
 
   const handleDelete = async (id: string) => {
     try {
-      const response = await fetch(`${API_URL}/api/jobs/${id}`, {
+      const response = await api(`/api/jobs/${id}`, {
         method: 'DELETE',
       });
       if (!response.ok) throw new Error('Failed to delete job');
@@ -58,7 +130,7 @@ function App() {
 
   const handleCreateJob = async (newJob: { name: string; description: string; schedule: string; grace_time: number }) => {
     try {
-      const response = await fetch(`${API_URL}/api/jobs`, {
+      const response = await api('/api/jobs', {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
@@ -82,6 +154,14 @@ function App() {
     );
   }
 
+  if (!user) {
+    return <LoginForm
+        ssoURL={ssoEnabled ? `${API_URL}/api/auth/oidc/login` : null}
+        registrationEnabled={registrationEnabled}
+        onSubmit={handleAuth}
+      />;
+  }
+
   return (
     <div className="min-h-screen bg-gray-50">
       <nav className="bg-white shadow">
@@ -92,7 +172,22 @@ function App() {
                 <h1 className="text-2xl font-bold text-gray-900">CronSentry</h1>
               </div>
             </div>
-            <div className="flex items-center">
+            <div className="flex items-center gap-x-4">
+              <span className="text-sm text-gray-500">{user.email}</span>
+              {ssoEnabled && (
+                <a
+                  href={`${API_URL}/api/auth/oidc/link`}
+                  className="text-sm font-medium text-gray-700 hover:text-gray-900"
+                >
+                  Link SSO
+                </a>
+              )}
+              <button
+                onClick={handleLogout}
+                className="text-sm font-medium text-gray-700 hover:text-gray-900"
+              >
+                Log Out
+              </button>
               <button
                 onClick={() => setIsModalOpen(true)}
                 className="inline-flex items-center gap-x-2 rounded-md bg-blue-600 px-3.5 py-2.5 text-sm font-semibold text-white shadow-sm hover:bg-blue-500 focus-visible:outline focus-visible:outline-2 focus-visible:outline-offset-2 focus-visible:outline-blue-600"
@@ -3,7 +3,7 @@ interface Job {
   name: string;
   description: string;
   schedule: string;
-  status: 'healthy' | 'late' | 'missing' | 'paused';
+  status: 'healthy' | 'late' | 'missing' | 'failed' | 'long_running' | 'paused';
   last_ping: string;
   next_expect: string;
 }
@@ -18,6 +18,8 @@ export function JobCard({ job, onDelete }: JobCardProps) {
     healthy: 'bg-green-100 text-green-800',
     late: 'bg-yellow-100 text-yellow-800',
     missing: 'bg-red-100 text-red-800',
+    failed: 'bg-red-100 text-red-800',
+    long_running: 'bg-yellow-100 text-yellow-800',
     paused: 'bg-gray-100 text-gray-800'
   };
 
@@ -0,0 +1,106 @@
+import { useState } from 'react';
+
+interface LoginFormProps {
+  ssoURL: string | null;
+  registrationEnabled: boolean;
+  onSubmit: (mode: 'login' | 'register', credentials: { email: string; name: string; password: string }) => Promise<string | null>;
+}
+
+export function LoginForm({ ssoURL, registrationEnabled, onSubmit }: LoginFormProps) {
+  const [mode, setMode] = useState<'login' | 'register'>('login');
+  const [error, setError] = useState<string | null>(null);
+
+  const handleSubmit = async (e: React.FormEvent<HTMLFormElement>) => {
+    e.preventDefault();
+    const formData = new FormData(e.currentTarget);
+    setError(await onSubmit(mode, {
+      email: formData.get('email') as string,
+      name: (formData.get('name') as string) || '',
+      password: formData.get('password') as string,
+    }));
+  };
+
+  return (
+    <div className="min-h-screen bg-gray-50 flex items-center justify-center p-4">
+      <div className="bg-white rounded-lg shadow max-w-md w-full p-6">
+        <h1 className="text-2xl font-bold text-gray-900 mb-4">CronSentry</h1>
+        <form onSubmit={handleSubmit} className="space-y-4">
+          {mode === 'register' && (
+            <div>
+              <label htmlFor="name" className="block text-sm font-medium text-gray-700">
+                Name
+              </label>
+              <input
+                type="text"
+                name="name"
+                id="name"
+                className="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-indigo-500 focus:ring-indigo-500 sm:text-sm"
+              />
+            </div>
+          )}
+
+          <div>
+            <label htmlFor="email" className="block text-sm font-medium text-gray-700">
+              Email
+            </label>
+            <input
+              type="email"
+              name="email"
+              id="email"
+              required
+              className="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-indigo-500 focus:ring-indigo-500 sm:text-sm"
+            />
+          </div>
+
+          <div>
+            <label htmlFor="password" className="block text-sm font-medium text-gray-700">
+              Password
+            </label>
+            <input
+              type="password"
+              name="password"
+              id="password"
+              required
+              minLength={mode === 'register' ? 8 : undefined}
+              className="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-indigo-500 focus:ring-indigo-500 sm:text-sm"
+            />
+          </div>
+
+          {error && <p className="text-sm text-red-600">{error}</p>}
+
+          <div className="mt-6 flex items-center justify-between">
+            {registrationEnabled ? (
+              <button
+                type="button"
+                onClick={() => {
+                  setMode(mode === 'login' ? 'register' : 'login');
+                  setError(null);
+                }}
+                className="text-sm font-medium text-indigo-600 hover:text-indigo-500"
+              >
+                {mode === 'login' ? 'Create an account' : 'Already have an account?'}
+              </button>
+            ) : (
+              <span />
+            )}
+            <button
+              type="submit"
+              className="px-4 py-2 text-sm font-medium text-white bg-indigo-600 border border-transparent rounded-md hover:bg-indigo-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-indigo-500"
+            >
+              {mode === 'login' ? 'Log In' : 'Sign Up'}
+            </button>
+          </div>
+        </form>
+
+        {ssoURL && (
+          <a
+            href={ssoURL}
+            className="mt-4 block w-full px-4 py-2 text-center text-sm font-medium text-gray-700 bg-white border border-gray-300 rounded-md hover:bg-gray-50"
+          >
+            Sign in with SSO
+          </a>
+        )}
+      </div>
+    </div>
+  );
+}